@@ -16,11 +16,9 @@ package main
 
 import (
 	"github.com/sandstorm/sku/internal/app/commands"
-	"github.com/sandstorm/sku/pkg/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 )
 
 func main() {
-	kubernetes.KubernetesInit()
 	commands.Execute()
 }
\ No newline at end of file
@@ -44,7 +44,11 @@ It is basically doing what is explained in https://github.com/kubernetes/kuberne
 		userHomeDir, _ := os.UserHomeDir()
 		kubeConfig := userHomeDir + "/.kube/config"
 
-		kubectlCommand := exec.Command("/usr/local/bin/kubectl", "config", "view", "--flatten")
+		kubectl, err := kubectlExecutable()
+		if err != nil {
+			log.Fatal(err)
+		}
+		kubectlCommand := exec.Command(kubectl, "config", "view", "--flatten")
 		// the 1st kubeconfig file overrides the last one.
 		kubectlCommand.Env = append(env, fmt.Sprintf(`KUBECONFIG=%s:%s`, otherKubeconfigFile, kubeConfig))
 		output, err := kubectlCommand.Output()
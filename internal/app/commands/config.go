@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/sandstorm/sku/pkg/config"
+	"github.com/sandstorm/sku/pkg/kubernetes"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+func BuildConfigCommand() *cobra.Command {
+	configCommand := &cobra.Command{
+		Use:   "config",
+		Short: "Show and change the sku configuration (global, per context and per namespace defaults)",
+		Long: fmt.Sprintf(`
+The sku configuration (~/.config/sku/config.yaml, or $%s) overrides the defaults
+of sku - globally, per context or per namespace. Namespace settings take precedence over
+context settings, which take precedence over the global settings.
+
+Every flag of a command can be configured as "<command path>.<flag>" (e.g. "restore.mariadb.dbHost"),
+or as "<flag>" for all commands having this flag. Flags given on the command line always win.
+
+Additionally, the following settings exist:
+
+  %-16s the kubectl executable (default: kubectl from PATH)
+  %-16s the borg executable of sku mount-backup (default: /usr/local/bin/borg)
+  %-16s where sku mount-backup mounts backups (default: ~/src/k8s/backup)
+  %-16s "true" protects the context or namespace (see sku context)
+  %-16s the default label selector of sku enter (logs.selector: of sku logs)
+
+In a directory containing a %s (or below), its settings take precedence; it can also pin
+the context and namespace (see sku config view). Executables and protection can only be set
+in the sku configuration.
+`, config.PathEnvVar, config.KeyKubectl, config.KeyBorg, config.KeyBackupMountPath, config.KeyProtected, "enter.selector", config.ProjectFileName),
+		Example: `
+	sku config set kubectl /opt/homebrew/bin/kubectl
+	sku --context production config set --scope context protected true
+	sku config set --scope namespace restore.mariadb.dbHost "eval:configmap('database').HOST"
+	sku config get kubectl
+	sku config view
+`,
+		Args: cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	effective := false
+	viewCommand := &cobra.Command{
+		Use:   "view",
+		Short: "Print the sku configuration file and the project file of the working directory",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if effective {
+				currentContext := kubernetes.CurrentContextName()
+				namespace := kubernetes.CurrentNamespace()
+				fmt.Printf("# settings for namespace %v in context %v\n", aurora.Green(namespace), aurora.Green(currentContext))
+				settings := config.Effective(currentContext, namespace)
+				for _, key := range settings.SortedKeys() {
+					fmt.Printf("%s: %s\n", key, settings[key])
+				}
+				return nil
+			}
+
+			content, err := yaml.Marshal(config.Current())
+			if err != nil {
+				return err
+			}
+			fmt.Printf("# %s\n%s", config.Current().Path(), content)
+
+			if project := config.Project(); project != nil {
+				content, err = yaml.Marshal(project)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("\n# %s (takes precedence)\n%s", project.Path(), content)
+			}
+			return nil
+		},
+	}
+	viewCommand.Flags().BoolVar(&effective, "effective", false, "print the merged settings applying to the current context and namespace")
+	configCommand.AddCommand(viewCommand)
+
+	getScope := ""
+	getCommand := &cobra.Command{
+		Use:   "get [key]",
+		Short: "Print the value of a setting for the current context and namespace",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var value string
+			var found bool
+			if len(getScope) == 0 {
+				value, found = config.Lookup(kubernetes.CurrentContextName(), kubernetes.CurrentNamespace(), args[0])
+			} else {
+				scope, err := config.ParseScope(getScope)
+				if err != nil {
+					return err
+				}
+				value, found = config.Current().Get(scope, kubernetes.CurrentContextName(), kubernetes.CurrentNamespace(), args[0])
+			}
+			if !found {
+				return fmt.Errorf("setting %s is not configured", args[0])
+			}
+			fmt.Println(value)
+			return nil
+		},
+	}
+	getCommand.Flags().StringVar(&getScope, "scope", "", "only look at the given scope (global, context or namespace); by default, the value applying to the current context and namespace is printed")
+	getCommand.RegisterFlagCompletionFunc("scope", completeConfigScope)
+	configCommand.AddCommand(getCommand)
+
+	setScope := string(config.ScopeGlobal)
+	setCommand := &cobra.Command{
+		Use:   "set [key] [value]",
+		Short: "Store a setting; an empty value removes it",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			scope, err := config.ParseScope(setScope)
+			if err != nil {
+				return err
+			}
+			currentContext := kubernetes.CurrentContextName()
+			namespace := kubernetes.CurrentNamespace()
+			config.Current().Set(scope, currentContext, namespace, args[0], args[1])
+			if err = config.Current().Save(); err != nil {
+				return fmt.Errorf("could not save the sku config: %w", err)
+			}
+
+			target := "globally"
+			switch scope {
+			case config.ScopeContext:
+				target = fmt.Sprintf("for context %v", aurora.Green(currentContext))
+			case config.ScopeNamespace:
+				target = fmt.Sprintf("for namespace %v in context %v", aurora.Green(namespace), aurora.Green(currentContext))
+			}
+			if len(args[1]) == 0 {
+				fmt.Printf("Removed %v %s.\n", aurora.Green(args[0]), target)
+			} else {
+				fmt.Printf("Set %v to %v %s.\n", aurora.Green(args[0]), aurora.Green(args[1]), target)
+			}
+			return nil
+		},
+	}
+	setCommand.Flags().StringVar(&setScope, "scope", setScope, "where to store the setting: global, context (the current context) or namespace (the current namespace of the current context)")
+	setCommand.RegisterFlagCompletionFunc("scope", completeConfigScope)
+	configCommand.AddCommand(setCommand)
+
+	return configCommand
+}
+
+func completeConfigScope(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return config.Scopes, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	RootCmd.AddCommand(BuildConfigCommand())
+}
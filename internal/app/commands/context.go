@@ -41,27 +41,33 @@ This command allows to switch between different configured Kubernetes clusters.`
 			return nil, cobra.ShellCompDirectiveNoFileComp
 		}
 
-		contexts := kubernetes.KubernetesApiConfig().Contexts
-		contextNames := make([]string, 0, len(contexts))
-		for context := range contexts {
-			contextNames = append(contextNames, context)
+		if err := initKubernetes(); err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
 		}
-		return contextNames, cobra.ShellCompDirectiveNoFileComp
+		return kubernetes.ContextNames(), cobra.ShellCompDirectiveNoFileComp
 	},
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
 			fmt.Printf("Contexts: \n")
 			kubernetes.PrintExistingContexts()
 		} else {
 			config := kubernetes.KubernetesApiConfig()
 			newContext := args[0]
-			kubernetes.EnsureContextExists(newContext)
+			if err := kubernetes.EnsureContextExists(newContext); err != nil {
+				fmt.Printf("%v\n", aurora.Red("Context not found; use one of the list below:"))
+				kubernetes.PrintExistingContexts()
+				return err
+			}
 
 			config.CurrentContext = newContext
-			clientcmd.ModifyConfig(clientcmd.NewDefaultPathOptions(), *config, false)
+			if err := clientcmd.ModifyConfig(clientcmd.NewDefaultPathOptions(), *config, false); err != nil {
+				return fmt.Errorf("could not write the kubeconfig: %w", err)
+			}
 
-			fmt.Printf("Switched to context %v.\n", aurora.Green(newContext))
+			fmt.Printf("Switched to context %v%s.\n", aurora.Green(newContext), sessionScopeHint())
 		}
+		warnIfProjectDisagrees()
+		return nil
 	},
 }
 
@@ -0,0 +1,315 @@
+package commands
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/sandstorm/sku/pkg/database"
+	"github.com/sandstorm/sku/pkg/kubernetes"
+	"github.com/spf13/cobra"
+)
+
+// dumpCompressions are the values of --compress, mapped to their file extensions.
+var dumpCompressions = map[string]string{
+	"none": "",
+	"gzip": ".gz",
+	"zstd": ".zst",
+}
+
+// dumpMetadata is written next to each dump, as <dump>.meta.json.
+type dumpMetadata struct {
+	Context           string    `json:"context"`
+	Namespace         string    `json:"namespace"`
+	Engine            string    `json:"engine"`
+	Host              string    `json:"host"`
+	Database          string    `json:"database"`
+	ServerVersion     string    `json:"serverVersion"`
+	Format            string    `json:"format"`
+	Compression       string    `json:"compression"`
+	SingleTransaction *bool     `json:"singleTransaction,omitempty"`
+	IncludeTables     []string  `json:"includeTables,omitempty"`
+	ExcludeTables     []string  `json:"excludeTables,omitempty"`
+	StartedAt         time.Time `json:"startedAt"`
+	FinishedAt        time.Time `json:"finishedAt"`
+}
+
+// BuildDatabaseDumpCommand builds "sku mysql dump" etc., writing a dump of the database to a local file.
+func BuildDatabaseDumpCommand(engine database.DumpingDatabaseEngine) *cobra.Command {
+	credentialExpressions := database.CredentialExpressions{}
+	proxyStrategy := ""
+	filename := ""
+	format := ""
+	compression := "none"
+	singleTransaction := true
+	includeTables := make([]string, 0)
+	excludeTables := make([]string, 0)
+
+	formatNames := make([]string, 0)
+	for _, dumpFormat := range engine.DumpFormats() {
+		formatNames = append(formatNames, dumpFormat.Name)
+	}
+
+	dumpCommand := &cobra.Command{
+		Use:   "dump",
+		Short: fmt.Sprintf("Write a dump of the %s database to a local file", engine.Name()),
+		Long: fmt.Sprintf(`
+Connects to the %s database like "sku %s" does (with the same credentials and settings), and writes a
+dump to a local file, optionally compressed with gzip or zstd (which needs the zstd command line tool).
+
+Next to the dump, <file>.meta.json records where it comes from: context, namespace, database, server version,
+and when the dump was started and finished.
+
+Supported formats: %s (default: %s).
+`, engine.Name(), engine.Name(), strings.Join(formatNames, ", "), formatNames[0]),
+		Example: fmt.Sprintf(`
+	sku %[1]s dump
+	sku %[1]s dump --compress zstd -f backup.sql.zst
+	sku %[1]s dump --exclude-table cache --exclude-table sessions
+`, engine.Name()),
+		Args: cobra.ExactArgs(0),
+		Annotations: map[string]string{
+			// the remembered credentials of "sku mysql --discover" apply to the dump as well
+			settingsPathAnnotation: engine.Name(),
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dumpFormat, err := database.FindDumpFormat(engine, format)
+			if err != nil {
+				return err
+			}
+			compressionExtension, found := dumpCompressions[compression]
+			if !found {
+				return fmt.Errorf("--compress must be none, gzip or zstd, but was %s", compression)
+			}
+			if dumpFormat.Compressed && compression != "none" {
+				return fmt.Errorf("the %s format is compressed by the dump tool itself; --compress is not supported", dumpFormat.Name)
+			}
+			if compression == "zstd" {
+				if _, err = exec.LookPath("zstd"); err != nil {
+					return fmt.Errorf("--compress zstd needs the zstd command line tool: %w", err)
+				}
+			}
+
+			credentials, err := credentialExpressions.Evaluate(kubernetes.NewEvalContext())
+			if err != nil {
+				return err
+			}
+			connection, err := connectDatabase(engine, credentials, proxyStrategy, 0)
+			if err != nil {
+				return err
+			}
+			defer connection.Close()
+			serverVersion, err := connection.ServerVersion()
+			if err != nil {
+				return err
+			}
+
+			if len(filename) == 0 {
+				filename = fmt.Sprintf("%s-%s-%s-%s%s%s", engine.Name(), kubernetes.CurrentNamespace(), connection.Credentials.Name,
+					time.Now().Format("20060102-150405"), dumpFormat.Extension, compressionExtension)
+			}
+			metadata := dumpMetadata{
+				Context:       kubernetes.CurrentContextName(),
+				Namespace:     kubernetes.CurrentNamespace(),
+				Engine:        engine.Name(),
+				Host:          fmt.Sprintf("%s:%d", connection.Credentials.Host, connection.Credentials.Port),
+				Database:      connection.Credentials.Name,
+				ServerVersion: serverVersion,
+				Format:        dumpFormat.Name,
+				Compression:   compression,
+				IncludeTables: includeTables,
+				ExcludeTables: excludeTables,
+				StartedAt:     time.Now(),
+			}
+			if engine.SupportsSingleTransaction() {
+				metadata.SingleTransaction = &singleTransaction
+			}
+
+			fmt.Println("")
+			fmt.Printf("4) Dumping %s (%s) to %s\n", aurora.Green(metadata.Database), serverVersion, aurora.Green(filename))
+			fmt.Println("")
+			dump, err := engine.ConfigurableDumpCommand(connection.Endpoint(), database.DumpOptions{
+				Format:            dumpFormat,
+				SingleTransaction: singleTransaction,
+				IncludeTables:     includeTables,
+				ExcludeTables:     excludeTables,
+				Directory:         filename,
+			})
+			if err != nil {
+				return fmt.Errorf("could not prepare the dump: %w", err)
+			}
+			if err = runDump(dump, dumpFormat, compression, filename); err != nil {
+				return err
+			}
+			metadata.FinishedAt = time.Now()
+
+			metadataJson, err := json.MarshalIndent(metadata, "", "  ")
+			if err != nil {
+				return err
+			}
+			if err = ioutil.WriteFile(filename+".meta.json", append(metadataJson, '\n'), 0644); err != nil {
+				return fmt.Errorf("could not write the metadata of the dump: %w", err)
+			}
+			fmt.Printf("Dumped to %v in %s.\n", aurora.Green(filename), metadata.FinishedAt.Sub(metadata.StartedAt).Round(time.Second))
+			return nil
+		},
+	}
+
+	addDatabaseFlags(dumpCommand, &credentialExpressions, &proxyStrategy, credentialExpressionsFor(engine.Name()))
+	dumpCommand.Flags().StringVarP(&filename, "file", "f", "", "the file (or directory) to write (default: <engine>-<namespace>-<database>-<time>.<extension>)")
+	dumpCommand.Flags().StringVar(&format, "format", "", fmt.Sprintf("the format of the dump: %s", strings.Join(formatNames, ", ")))
+	dumpCommand.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return formatNames, cobra.ShellCompDirectiveNoFileComp
+	})
+	dumpCommand.Flags().StringVar(&compression, "compress", compression, "compress the dump: none, gzip or zstd (not for formats the dump tool compresses itself)")
+	dumpCommand.RegisterFlagCompletionFunc("compress", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"none", "gzip", "zstd"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	if engine.SupportsSingleTransaction() {
+		dumpCommand.Flags().BoolVar(&singleTransaction, "single-transaction", singleTransaction, "dump all tables in one consistent snapshot without locking them")
+	}
+	dumpCommand.Flags().StringArrayVar(&includeTables, "include-table", includeTables, "only dump this table (can be given multiple times)")
+	dumpCommand.Flags().StringArrayVar(&excludeTables, "exclude-table", excludeTables, "do not dump this table (can be given multiple times)")
+
+	return dumpCommand
+}
+
+// runDump runs the dump command, writing its output (compressed, if requested) to filename, and printing its
+// progress together with the number of bytes written so far. A partially written dump is removed on errors.
+func runDump(dump *exec.Cmd, dumpFormat database.DumpFormat, compression string, filename string) (err error) {
+	var written int64
+	if dumpFormat.Directory {
+		// the dump tool creates the directory; only remove it if it did not exist before.
+		if _, statErr := os.Stat(filename); os.IsNotExist(statErr) {
+			defer func() {
+				if err != nil {
+					os.RemoveAll(filename)
+				}
+			}()
+		}
+	} else {
+		var file *os.File
+		file, err = os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return fmt.Errorf("could not create %s: %w", filename, err)
+		}
+		defer func() {
+			if closeErr := file.Close(); err == nil && closeErr != nil {
+				err = fmt.Errorf("could not write %s: %w", filename, closeErr)
+			}
+			if err != nil {
+				os.Remove(filename)
+			}
+		}()
+
+		var output io.WriteCloser
+		output, err = compressedWriter(file, compression)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := output.Close(); err == nil && closeErr != nil {
+				err = fmt.Errorf("could not compress %s: %w", filename, closeErr)
+			}
+		}()
+		dump.Stdout = &countingWriter{output, &written}
+	}
+
+	var progress io.ReadCloser
+	progress, err = dump.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err = dump.Start(); err != nil {
+		return fmt.Errorf("could not start %s: %w", dump.Path, err)
+	}
+	scanner := bufio.NewScanner(progress)
+	for scanner.Scan() {
+		if dumpFormat.Directory {
+			fmt.Printf("   %s\n", scanner.Text())
+		} else {
+			fmt.Printf("   %s %v\n", scanner.Text(), aurora.Faint(fmt.Sprintf("(%s written)", formatByteSize(atomic.LoadInt64(&written)))))
+		}
+	}
+	if err = dump.Wait(); err != nil {
+		return fmt.Errorf("%s failed: %w", dump.Path, err)
+	}
+	return nil
+}
+
+// compressedWriter compresses everything written to it into file; closing it does not close file.
+func compressedWriter(file *os.File, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case "gzip":
+		return gzip.NewWriter(file), nil
+	case "zstd":
+		zstd := exec.Command("zstd", "--quiet", "--threads=0", "--stdout")
+		zstd.Stdout = file
+		zstd.Stderr = os.Stderr
+		input, err := zstd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err = zstd.Start(); err != nil {
+			return nil, fmt.Errorf("could not start zstd: %w", err)
+		}
+		return &commandWriter{input, zstd}, nil
+	default:
+		return nopWriteCloser{file}, nil
+	}
+}
+
+// commandWriter writes to the Stdin of a command; Close waits until the command has finished.
+type commandWriter struct {
+	io.WriteCloser
+	command *exec.Cmd
+}
+
+func (w *commandWriter) Close() error {
+	if err := w.WriteCloser.Close(); err != nil {
+		return err
+	}
+	return w.command.Wait()
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// countingWriter counts the bytes written through it; the count is read concurrently.
+type countingWriter struct {
+	io.Writer
+	written *int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	atomic.AddInt64(w.written, int64(n))
+	return n, err
+}
+
+func formatByteSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	divisor, exponent := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		divisor *= unit
+		exponent++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(divisor), "KMGTPE"[exponent])
+}
@@ -0,0 +1,271 @@
+package commands
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/phayes/freeport"
+	"github.com/sandstorm/sku/pkg/config"
+	"github.com/sandstorm/sku/pkg/database"
+	"github.com/sandstorm/sku/pkg/kubernetes"
+	"github.com/spf13/cobra"
+)
+
+// defaultCredentialExpressions are used if no --db* flags are given; see credentialExpressionsFor.
+var defaultCredentialExpressions = map[string]database.CredentialExpressions{
+	"mongodb": {
+		Host:     "eval:configmap(selectInteractively('MONGO_HOST')).MONGO_HOST",
+		Name:     "eval:configmap(selectInteractively('MONGO_HOST')).MONGO_DATABASE",
+		User:     "eval:configmap(selectInteractively('MONGO_HOST')).MONGO_USER",
+		Password: "eval:secret(selectInteractively('MONGO_HOST')).MONGO_PASSWORD",
+	},
+	"redis": {
+		Host:     "eval:configmap(selectInteractively('REDIS_HOST')).REDIS_HOST",
+		Name:     "0",
+		Password: "eval:secret(selectInteractively('REDIS_HOST')).REDIS_PASSWORD",
+	},
+}
+
+// credentialExpressionsFor returns the default credential expressions of an engine; falling back to
+// the Sandstorm conventions (ConfigMap with DB_HOST, DB_NAME, DB_USER and a Secret with DB_PASSWORD).
+func credentialExpressionsFor(engineName string) database.CredentialExpressions {
+	if expressions, ok := defaultCredentialExpressions[engineName]; ok {
+		return expressions
+	}
+	return database.CredentialExpressions{
+		Host:     "eval:configmap(selectInteractively('DB_HOST')).DB_HOST",
+		Name:     "eval:configmap(selectInteractively('DB_HOST')).DB_NAME",
+		User:     "eval:configmap(selectInteractively('DB_HOST')).DB_USER",
+		Password: "eval:secret(selectInteractively('DB_HOST')).DB_PASSWORD",
+	}
+}
+
+// BuildDatabaseEngineCommand builds the command to enter a database of the given engine
+// via one of its client tools, e.g. "sku mysql cli" or "sku db mysql cli".
+func BuildDatabaseEngineCommand(engine database.DatabaseEngine, use string) *cobra.Command {
+	credentialExpressions := database.CredentialExpressions{}
+	proxyStrategy := ""
+	discover := false
+	localPort := 0
+
+	toolNames := database.ClientToolNames(engine)
+	toolDescriptions := make([]string, 0, len(toolNames))
+	for _, tool := range engine.ClientTools() {
+		toolDescriptions = append(toolDescriptions, fmt.Sprintf("  %-10s %s", tool.Name, tool.Description))
+	}
+
+	var databaseEngineCommand = &cobra.Command{
+		Use:                   fmt.Sprintf("%s [%s] (extra-params)", use, strings.Join(toolNames, "|")),
+		DisableFlagsInUseLine: true,
+		ValidArgs:             toolNames,
+		Args:                  cobra.MinimumNArgs(1),
+		Short:                 fmt.Sprintf("Build a %s connection and enter it via one of the given tools", engine.Name()),
+		Long: fmt.Sprintf(`
+Build a connection to a %s database inside the cluster, and enter it via one of the following tools:
+
+%s
+
+Extra parameters are passed to command line tools.
+
+If the credentials are not in the ConfigMap / Secret the defaults expect, use --discover: it lets you
+map the environment variables of a Deployment or StatefulSet to the credentials once, and remembers
+the mapping for the current namespace.
+
+The local port of the tunnel is chosen once per database and remembered, so that connections saved in
+GUI tools keep working; --local-port sets it explicitly (and is remembered as well). To keep the tunnel open in the background, use
+"sku tunnel up %s".
+`, engine.Name(), strings.Join(toolDescriptions, "\n"), engine.Name()),
+		Annotations: map[string]string{
+			// the remembered credentials of --discover apply to "sku mysql" and "sku db mysql" alike
+			settingsPathAnnotation: engine.Name(),
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tool, err := database.FindClientTool(engine, args[0])
+			if err != nil {
+				return err
+			}
+
+			if discover {
+				if err = discoverCredentials(cmd, engine, &credentialExpressions); err != nil {
+					return err
+				}
+			}
+
+			credentials, err := credentialExpressions.Evaluate(kubernetes.NewEvalContext())
+			if err != nil {
+				return err
+			}
+			// a local-port setting is applied to the flag as well; databaseLocalPort prefers the remembered port
+			// of the database over it
+			if !cmd.Flags().Changed(localPortFlag) {
+				localPort = 0
+			}
+			localPort, err = databaseLocalPort(engine, credentials.Name, localPort)
+			if err != nil {
+				return err
+			}
+			connection, err := connectDatabase(engine, credentials, proxyStrategy, localPort)
+			if err != nil {
+				return err
+			}
+			defer connection.Close()
+
+			err = tool.Run(connection.Endpoint(), args[1:])
+			if err != nil {
+				return fmt.Errorf("%s failed: %w", tool.Name, err)
+			}
+			return nil
+		},
+	}
+
+	addDatabaseFlags(databaseEngineCommand, &credentialExpressions, &proxyStrategy, credentialExpressionsFor(engine.Name()))
+	databaseEngineCommand.Flags().BoolVar(&database.ShowPasswords, "show-password", false, "print the password GUI tools ask for, instead of copying it to the clipboard")
+	databaseEngineCommand.Flags().BoolVar(&discover, "discover", false, "map the environment variables of a workload to the credentials, and remember the mapping for the current namespace")
+	if dumpingEngine, ok := engine.(database.DumpingDatabaseEngine); ok {
+		databaseEngineCommand.AddCommand(BuildDatabaseDumpCommand(dumpingEngine))
+		databaseEngineCommand.Long += fmt.Sprintf("\nTo write a dump of the database to a local file, use \"sku %s dump\".\n", engine.Name())
+	}
+	databaseEngineCommand.Flags().IntVar(&localPort, localPortFlag, 0, "local port of the tunnel (remembered for the database); by default, a free port is chosen once")
+
+	return databaseEngineCommand
+}
+
+// addDatabaseFlags registers the --db* credential flags and --proxy-strategy.
+func addDatabaseFlags(cmd *cobra.Command, credentialExpressions *database.CredentialExpressions, proxyStrategy *string, defaults database.CredentialExpressions) {
+	cmd.Flags().StringVarP(&credentialExpressions.Host, "dbHost", "", defaults.Host, "database host (as reachable from within the cluster); can be an eval: expression")
+	cmd.Flags().StringVarP(&credentialExpressions.Port, "dbPort", "", defaults.Port, "database port; defaults to the standard port of the database engine; can be an eval: expression")
+	cmd.Flags().StringVarP(&credentialExpressions.Name, "dbName", "", defaults.Name, "database name; can be an eval: expression")
+	cmd.Flags().StringVarP(&credentialExpressions.User, "dbUser", "", defaults.User, "database user; can be an eval: expression")
+	cmd.Flags().StringVarP(&credentialExpressions.Password, "dbPassword", "", defaults.Password, "database password; can be an eval: expression")
+	cmd.Flags().StringVarP(proxyStrategy, "proxy-strategy", "", string(database.ProxyStrategyDebug), "how to reach the database from within the cluster: debug (debug container in a running pod), pod (dedicated proxy pod) or direct (port-forward to the selected pod)")
+	cmd.RegisterFlagCompletionFunc("proxy-strategy", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return database.ProxyStrategies, cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+// credentialFlags maps the --db* flags to the credential expressions.
+func credentialFlags(credentialExpressions *database.CredentialExpressions) map[string]*string {
+	return map[string]*string{
+		"dbHost":     &credentialExpressions.Host,
+		"dbPort":     &credentialExpressions.Port,
+		"dbName":     &credentialExpressions.Name,
+		"dbUser":     &credentialExpressions.User,
+		"dbPassword": &credentialExpressions.Password,
+	}
+}
+
+// discoverCredentials runs database.DiscoverCredentials, stores the result as settings of the current namespace,
+// and uses it for all --db* flags not given on the command line.
+func discoverCredentials(cmd *cobra.Command, engine database.DatabaseEngine, credentialExpressions *database.CredentialExpressions) error {
+	discovered, err := database.DiscoverCredentials(engine)
+	if err != nil {
+		return err
+	}
+
+	currentContext := kubernetes.CurrentContextName()
+	namespace := kubernetes.CurrentNamespace()
+	discoveredFlags := credentialFlags(&discovered)
+	for flag, target := range credentialFlags(credentialExpressions) {
+		config.Current().Set(config.ScopeNamespace, currentContext, namespace, engine.Name()+"."+flag, *discoveredFlags[flag])
+		if !cmd.Flags().Changed(flag) {
+			*target = *discoveredFlags[flag]
+		}
+	}
+	if err = config.Current().Save(); err != nil {
+		return fmt.Errorf("could not save the sku config: %w", err)
+	}
+	fmt.Printf("Remembered the credentials for %v in namespace %v (see sku config view).\n", aurora.Green(engine.Name()), aurora.Green(namespace))
+	return nil
+}
+
+// localPortFlag is the flag (and the setting, e.g. mysql.local-port) of the local port of database tunnels.
+const localPortFlag = "local-port"
+
+// localPortSetting is the setting remembering the local port of a database, e.g. mysql.local-port.shop.
+func localPortSetting(engine database.DatabaseEngine, databaseName string) string {
+	if len(databaseName) == 0 {
+		return engine.Name() + "." + localPortFlag
+	}
+	return engine.Name() + "." + localPortFlag + "." + databaseName
+}
+
+// databaseLocalPort returns the local port for the database tunnel: --local-port (0 if not given on the command
+// line), or else the port remembered for the database, or else the setting for all databases of the engine (e.g.
+// mysql.local-port). Otherwise, a free port is chosen. The port is remembered for the database in the current
+// namespace, so that connections saved in GUI tools keep working.
+func databaseLocalPort(engine database.DatabaseEngine, databaseName string, localPort int) (int, error) {
+	key := localPortSetting(engine, databaseName)
+	if localPort == 0 {
+		localPort, _ = strconv.Atoi(setting(key, setting(localPortSetting(engine, ""), "0")))
+	}
+
+	if localPort != 0 {
+		listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", localPort))
+		if err != nil {
+			return 0, fmt.Errorf("local port %d is in use (by another tunnel? see sku tunnel ls), pass another one via --%s: %w", localPort, localPortFlag, err)
+		}
+		listener.Close()
+	} else {
+		var err error
+		localPort, err = freeport.GetFreePort()
+		if err != nil {
+			return 0, fmt.Errorf("did not find a free port: %w", err)
+		}
+	}
+
+	currentContext := kubernetes.CurrentContextName()
+	namespace := kubernetes.CurrentNamespace()
+	if remembered, _ := config.Current().Get(config.ScopeNamespace, currentContext, namespace, key); remembered == strconv.Itoa(localPort) {
+		return localPort, nil
+	}
+	config.Current().Set(config.ScopeNamespace, currentContext, namespace, key, strconv.Itoa(localPort))
+	if err := config.Current().Save(); err != nil {
+		return 0, fmt.Errorf("could not save the sku config: %w", err)
+	}
+	target := engine.Name()
+	if len(databaseName) > 0 {
+		target += " database " + databaseName
+	}
+	fmt.Printf("Remembered local port %v for %v in namespace %v (see sku config view).\n", aurora.Green(localPort), aurora.Green(target), aurora.Green(namespace))
+	return localPort, nil
+}
+
+// connectDatabase connects to the database through the cluster.
+func connectDatabase(engine database.DatabaseEngine, credentials database.Credentials, proxyStrategy string, localPort int) (*database.Connection, error) {
+	parsedProxyStrategy, err := database.ParseProxyStrategy(proxyStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	return database.ConnectThroughPod(engine, credentials, parsedProxyStrategy, localPort)
+}
+
+var dbCommand = &cobra.Command{
+	Use:   "db",
+	Short: "Build a connection to a database of any supported engine and enter it",
+	Long: `
+Build a connection to a database inside the cluster, and enter it via one of the supported tools.
+See the sub-commands for the supported database engines.
+`,
+	Example: `
+	sku db mysql cli
+	sku db postgres pgcli
+	sku db mongodb mongosh
+	sku db redis redis-cli
+`,
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+func init() {
+	for _, engineName := range database.EngineNames() {
+		engine, _ := database.Engine(engineName)
+		dbCommand.AddCommand(BuildDatabaseEngineCommand(engine, engineName))
+	}
+	RootCmd.AddCommand(dbCommand)
+}
@@ -18,26 +18,38 @@ import (
 	"context"
 	"fmt"
 	"github.com/logrusorgru/aurora"
+	"github.com/sandstorm/sku/pkg/config"
 	"github.com/sandstorm/sku/pkg/kubernetes"
 	"github.com/sandstorm/sku/pkg/utility"
 	"github.com/spf13/cobra"
 	clientV1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
-	"os"
-	"syscall"
+	"math/rand"
+	"sort"
+	"time"
 )
 
+// set via --command and --any
+var enterCommand string
+var enterAnyReplica bool
+
 // enterCmd represents the enter command
 var enterCmd = &cobra.Command{
 	Use:   "enter",
 	Short: "Enter an interactive shell in a Kubernetes container",
 	Long: `
 Enter an interactive shell in a pod of the current namespace.
-To select the pods you want to enter, you'll see a choice list.
+To select the pods you want to enter, you'll see a choice list, grouped
+by the owning Deployment, StatefulSet, DaemonSet or (Cron)Job. Type
+to search it fuzzily.
 
 Optionally, you can restrict the pod list by specifying a label
 selector.
 
+With --any, you choose the workload instead of the pod, and a random
+ready replica of it is entered.
+
+With --command, the command is run instead of an interactive shell.
 `,
 	Example: `
 # get presented a choice list which container to enter
@@ -48,78 +60,157 @@ selector.
 	sku enter app=foo
 	sku enter app=foo,component=app
 
+# enter any replica of a Deployment; in a specific container
+	sku enter --any --container php
+
+# run a one-off command
+	sku enter app=neos --any --command "./flow doctrine:migrate"
 `,
 	Args: cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		currentContext := kubernetes.KubernetesApiConfig().CurrentContext
-		k8sContextDefinition := kubernetes.KubernetesApiConfig().Contexts[currentContext]
-		labelSelector := ""
+	RunE: func(cmd *cobra.Command, args []string) error {
+		currentContext := kubernetes.CurrentContextName()
+		namespace := kubernetes.CurrentNamespace()
+		labelSelector := setting("enter."+config.KeySelector, "")
 		if len(args) == 1 {
 			labelSelector = args[0]
-			fmt.Printf("Listing pods with label %v in namespace %v in k8sContextDefinition %v.\n", aurora.Green(labelSelector), aurora.Green(k8sContextDefinition.Namespace), aurora.Green(currentContext))
+		}
+		if len(labelSelector) > 0 {
+			fmt.Printf("Listing pods with label %v in namespace %v in context %v.\n", aurora.Green(labelSelector), aurora.Green(namespace), aurora.Green(currentContext))
 		} else {
-			fmt.Printf("Listing pods in namespace %v in k8sContextDefinition %v.\n", aurora.Green(k8sContextDefinition.Namespace), aurora.Green(currentContext))
+			fmt.Printf("Listing pods in namespace %v in context %v.\n", aurora.Green(namespace), aurora.Green(currentContext))
 		}
 
-		podList, _ := kubernetes.KubernetesClientset().CoreV1().Pods(k8sContextDefinition.Namespace).List(context.Background(), v1.ListOptions{
-			LabelSelector: labelSelector,
-		})
-
-		numberOfRunningPods := 0
-		lastRunningPodIndex := 0
-		for i, pod := range podList.Items {
-			if pod.Status.Phase == clientV1.PodRunning {
-				fmt.Printf("%d: %v - %v \n", i, aurora.Green(pod.Name), pod.Labels)
-				numberOfRunningPods++
-			} else {
-				fmt.Printf("%d: %v - %v \n", i, pod.Name, pod.Labels)
-			}
+		if len(kubernetes.Preselected.Pod) == 0 && len(labelSelector) > 0 && utility.NonInteractive {
+			// in non-interactive mode, the label selector argument acts like --pod
+			kubernetes.Preselected.Pod = labelSelector
 		}
 
-		var i int
-		switch numberOfRunningPods {
-		case 0:
-			fmt.Printf("No running pods. Exiting!\n")
-			os.Exit(1)
-		case 1:
-			i = lastRunningPodIndex
-		default:
-			i = utility.GetNumberChoice()
+		pod, err := selectPodToEnter(namespace, labelSelector)
+		if err != nil {
+			return err
 		}
 
-		containerName := ""
-		if len(podList.Items[i].Spec.Containers) > 1 {
-			fmt.Printf("Which container?.\n")
-			for ci, c := range podList.Items[i].Spec.Containers {
-				fmt.Printf("%d: %v\n", ci, aurora.Green(c.Name))
-			}
-			ci := utility.GetNumberChoice()
-
-			containerName = podList.Items[i].Spec.Containers[ci].Name
+		containerName, err := kubernetes.SelectContainer(pod)
+		if err != nil {
+			return fmt.Errorf("no container selected: %w", err)
 		}
 
-		fmt.Printf("Connecting to %v %s in %v:\n", aurora.Green(podList.Items[i].Name), containerName, aurora.Green(currentContext))
+		fmt.Printf("Connecting to %v %s in %v:\n", aurora.Green(pod.Name), containerName, aurora.Green(currentContext))
 
-		kubectlArgs := []string{"kubectl", "exec", "-it"}
-		if containerName != "" {
-			kubectlArgs = append(kubectlArgs, "-c", containerName)
+		command := []string{"/bin/sh", "-c", "[ -e /bin/bash ] && exec /bin/bash || exec /bin/sh"}
+		if len(enterCommand) > 0 {
+			command = []string{"/bin/sh", "-c", enterCommand}
 		}
-		// enter bash or sh
-		kubectlArgs = append(kubectlArgs, podList.Items[i].Name, "--", "/bin/sh", "-c", "[ -e /bin/bash ] && exec /bin/bash || exec /bin/sh")
-		syscall.Exec("/usr/local/bin/kubectl", kubectlArgs, os.Environ())
+		return kubernetes.Exec(namespace, pod.Name, containerName, command)
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(enterCmd)
 
-	// Here you will define your flags and configuration settings.
+	enterCmd.Flags().StringVar(&enterCommand, "command", "", "run this command (via /bin/sh -c) instead of an interactive shell")
+	enterCmd.Flags().BoolVar(&enterAnyReplica, "any", false, "choose the workload instead of the pod, and enter a random ready replica of it")
+}
+
+// selectPodToEnter returns the pod given via --pod; or lets the user choose one of the running pods (grouped by owner),
+// or with --any, one of the owners.
+func selectPodToEnter(namespace, labelSelector string) (*clientV1.Pod, error) {
+	if len(kubernetes.Preselected.Pod) > 0 {
+		podName, err := kubernetes.SelectPod("")
+		if err != nil {
+			return nil, err
+		}
+		pod, err := kubernetes.KubernetesClientset().CoreV1().Pods(namespace).Get(context.Background(), podName, v1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", kubernetes.ErrPodNotFound, podName, err)
+		}
+		return pod, nil
+	}
+
+	podList, err := kubernetes.KubernetesClientset().CoreV1().Pods(namespace).List(context.Background(), v1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pods could not be listed: %w", err)
+	}
+
+	runningPods := make([]clientV1.Pod, 0, len(podList.Items))
+	for _, pod := range podList.Items {
+		if pod.Status.Phase == clientV1.PodRunning {
+			runningPods = append(runningPods, pod)
+		}
+	}
+	if len(runningPods) == 0 {
+		return nil, fmt.Errorf("%w in namespace %s. Exiting!", kubernetes.ErrPodNotFound, namespace)
+	}
+
+	owners := kubernetes.PodOwners(namespace, runningPods)
+	for name, owner := range owners {
+		if len(owner) == 0 {
+			owners[name] = "(no owner)"
+		}
+	}
+	sort.SliceStable(runningPods, func(i, j int) bool {
+		if owners[runningPods[i].Name] != owners[runningPods[j].Name] {
+			return owners[runningPods[i].Name] < owners[runningPods[j].Name]
+		}
+		return runningPods[i].Name < runningPods[j].Name
+	})
+
+	if enterAnyReplica {
+		return selectRandomReplica(runningPods, owners)
+	}
+
+	if len(runningPods) == 1 {
+		fmt.Printf("%s found exactly one running pod, using this one: %s\n", aurora.Yellow("INFO:"), runningPods[0].Name)
+		return &runningPods[0], nil
+	}
+
+	items := make([]string, 0, len(runningPods))
+	for i := range runningPods {
+		readiness := "ready"
+		if !kubernetes.IsPodReady(&runningPods[i]) {
+			readiness = "not ready"
+		}
+		items = append(items, fmt.Sprintf("%-40s %s (%s)", owners[runningPods[i].Name], runningPods[i].Name, readiness))
+	}
+	i, err := utility.SelectFuzzy("Which pod? (type to search)", items, "pass --pod with a pod name or label selector")
+	if err != nil {
+		return nil, kubernetes.PromptError(err)
+	}
+	return &runningPods[i], nil
+}
+
+// selectRandomReplica lets the user choose an owner of the pods, and returns a random ready pod of it.
+func selectRandomReplica(pods []clientV1.Pod, owners map[string]string) (*clientV1.Pod, error) {
+	ownerNames := make([]string, 0)
+	for _, pod := range pods {
+		owner := owners[pod.Name]
+		if len(ownerNames) == 0 || ownerNames[len(ownerNames)-1] != owner {
+			ownerNames = append(ownerNames, owner)
+		}
+	}
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// enterCmd.PersistentFlags().String("foo", "", "A help for foo")
+	owner := ownerNames[0]
+	if len(ownerNames) > 1 {
+		i, err := utility.SelectFuzzy("Which workload? (type to search)", ownerNames, "pass a label selector matching a single workload")
+		if err != nil {
+			return nil, kubernetes.PromptError(err)
+		}
+		owner = ownerNames[i]
+	}
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// enterCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	readyPods := make([]*clientV1.Pod, 0)
+	for i := range pods {
+		if owners[pods[i].Name] == owner && kubernetes.IsPodReady(&pods[i]) {
+			readyPods = append(readyPods, &pods[i])
+		}
+	}
+	if len(readyPods) == 0 {
+		return nil, fmt.Errorf("%w: no ready replica of %s", kubernetes.ErrPodNotFound, owner)
+	}
+
+	pod := readyPods[rand.New(rand.NewSource(time.Now().UnixNano())).Intn(len(readyPods))]
+	fmt.Printf("%s using the random ready replica %s of %s\n", aurora.Yellow("INFO:"), pod.Name, owner)
+	return pod, nil
 }
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/logrusorgru/aurora/v3"
+	"github.com/sandstorm/sku/pkg/database"
+	"github.com/sandstorm/sku/pkg/kubernetes"
+	"github.com/spf13/cobra"
+)
+
+// forwardTarget is a target of sku forward: [localPort:]host:port
+type forwardTarget struct {
+	localPort int
+	host      string
+	port      int
+}
+
+func parseForwardTarget(target string) (forwardTarget, error) {
+	parts := strings.Split(target, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return forwardTarget{}, fmt.Errorf("target %s must be given as host:port or localPort:host:port", target)
+	}
+
+	parsed := forwardTarget{host: parts[len(parts)-2]}
+	var err error
+	if parsed.port, err = strconv.Atoi(parts[len(parts)-1]); err != nil {
+		return forwardTarget{}, fmt.Errorf("port of target %s is not a number", target)
+	}
+	if len(parts) == 3 {
+		if parsed.localPort, err = strconv.Atoi(parts[0]); err != nil {
+			return forwardTarget{}, fmt.Errorf("local port of target %s is not a number", target)
+		}
+	}
+	return parsed, nil
+}
+
+func BuildForwardCommand() *cobra.Command {
+	proxyStrategy := string(database.ProxyStrategyPod)
+
+	forwardCommand := &cobra.Command{
+		Use:   "forward [localPort:]host:port...",
+		Short: "Forward local ports to hosts only reachable from within the cluster (Services, external hosts, ...)",
+		Long: `
+Opens tunnels from local ports to any host:port reachable from within the current namespace, e.g. Services,
+Elasticsearch, an SMTP sink, or external hosts which only accept connections from the cluster. Several targets
+can be given at once; without a local port, a free one is chosen.
+
+The tunnels lead through a socat proxy (see --proxy-strategy); by default, a short-lived proxy pod carrying the
+labels of a workload you choose, so that NetworkPolicies apply. If the proxy pod dies, it is re-created and the
+tunnel is re-opened on the same local port. Ctrl-C closes all tunnels and removes the proxy pods.
+`,
+		Example: `
+	sku forward elasticsearch:9200
+	sku forward 9200:elasticsearch:9200 mailhog:8025 smtp.example.com:587
+	sku forward --workload Deployment/web --proxy-strategy pod redis:6379
+`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets := make([]forwardTarget, 0, len(args))
+			for _, arg := range args {
+				target, err := parseForwardTarget(arg)
+				if err != nil {
+					return err
+				}
+				targets = append(targets, target)
+			}
+			parsedProxyStrategy, err := database.ParseProxyStrategy(proxyStrategy)
+			if err != nil {
+				return err
+			}
+
+			// registered before opening the tunnels, so that Ctrl-C during the setup still cleans up
+			interrupted := make(chan os.Signal, 1)
+			signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+			defer signal.Stop(interrupted)
+
+			fmt.Printf("Forwarding into namespace %v in context %v.\n", aurora.Green(kubernetes.CurrentNamespace()), aurora.Green(kubernetes.CurrentContextName()))
+			proxy, err := database.SelectProxy(parsedProxyStrategy)
+			if err != nil {
+				return err
+			}
+
+			forwards := make([]*database.Forward, 0, len(targets))
+			defer func() {
+				for _, forward := range forwards {
+					forward.Close()
+				}
+			}()
+			for _, target := range targets {
+				forward, err := database.StartForward(proxy, target.localPort, target.host, target.port)
+				if err != nil {
+					return err
+				}
+				forwards = append(forwards, forward)
+			}
+
+			fmt.Println()
+			for _, forward := range forwards {
+				fmt.Printf("  %-22s -> %-30s (via pod %s)\n", aurora.Green(fmt.Sprintf("127.0.0.1:%d", forward.LocalPort)), forward.Target(), forward.PodName())
+			}
+			fmt.Println()
+			fmt.Println(aurora.Bold("Press Ctrl-C to close the tunnels."))
+
+			<-interrupted
+			fmt.Println("Closing the tunnels.")
+			return nil
+		},
+	}
+
+	forwardCommand.Flags().StringVarP(&proxyStrategy, "proxy-strategy", "", proxyStrategy, "how to reach the targets from within the cluster: pod (dedicated proxy pod), debug (debug container in a running pod) or direct (port-forward to the selected pod; the host is ignored)")
+	forwardCommand.RegisterFlagCompletionFunc("proxy-strategy", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return database.ProxyStrategies, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	return forwardCommand
+}
+
+func init() {
+	RootCmd.AddCommand(BuildForwardCommand())
+}
@@ -0,0 +1,296 @@
+package commands
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/sandstorm/sku/internal/app/commands/restore"
+	"github.com/sandstorm/sku/pkg/kubernetes"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+	clientV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func BuildLogsExportCommand() *cobra.Command {
+	var filename string
+	var since string
+
+	logsExportCommand := &cobra.Command{
+		Use:   "export [label selector]",
+		Short: "Write the logs, pods, events and workloads of the current namespace to a tar.gz for incident analysis",
+		Long: `
+Collects everything needed to analyze an incident into a tar.gz - for all pods of the current namespace,
+or the pods matching the label selector:
+
+  events.txt                           the recent events of the namespace
+  pods/<pod>/pod.yaml                  the pod including its status
+  pods/<pod>/events.txt                the recent events of the pod
+  pods/<pod>/<container>.log           the log of each container (including init containers)
+  pods/<pod>/<container>.previous.log  the log of the previous instance of restarted containers
+  workloads/<Kind>-<name>.yaml         the Deployments, StatefulSets, ... owning the pods
+
+The workload manifests are cleaned like by "sku restore clean-manifests", so e.g. managed fields and the
+last applied configuration (which may contain secrets) are removed; the pods are cleaned in the same way,
+but keep their status. Log lines are prefixed with their time.
+`,
+		Example: `
+	sku logs export
+	sku logs export app=foo --since 2h -f incident-4711.tar.gz
+`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			namespace := kubernetes.CurrentNamespace()
+			labelSelector := ""
+			if len(args) == 1 {
+				labelSelector = args[0]
+			}
+			sinceTime, err := parseLogTime(since)
+			if err != nil {
+				return fmt.Errorf("invalid --since: %w", err)
+			}
+			if len(filename) == 0 {
+				filename = fmt.Sprintf("logs-%s-%s.tar.gz", namespace, time.Now().Format("20060102-150405"))
+			}
+
+			podList, err := kubernetes.KubernetesClientset().CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{
+				LabelSelector: labelSelector,
+			})
+			if err != nil {
+				return fmt.Errorf("pods could not be listed: %w", err)
+			}
+			if len(podList.Items) == 0 {
+				return fmt.Errorf("%w: no pods in namespace %s matching %q", kubernetes.ErrPodNotFound, namespace, labelSelector)
+			}
+			events, err := kubernetes.NamespaceEvents(namespace)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Exporting %d pods of namespace %v in %v to %v.\n", len(podList.Items), aurora.Green(namespace), aurora.Green(kubernetes.CurrentContextName()), aurora.Green(filename))
+			err = writeFileOnSuccess(filename, func(file *os.File) error {
+				bundle := newTarBundle(file, strings.TrimSuffix(path.Base(filename), ".tar.gz"))
+				if err := bundle.add("events.txt", formatEvents(events)); err != nil {
+					return err
+				}
+
+				for i := range podList.Items {
+					pod := &podList.Items[i]
+					fmt.Printf("- %s\n", pod.Name)
+					if err := exportPod(bundle, namespace, pod, events, sinceTime); err != nil {
+						return err
+					}
+				}
+
+				owners := kubernetes.PodOwners(namespace, podList.Items)
+				exported := make(map[string]bool)
+				for _, pod := range podList.Items {
+					owner := owners[pod.Name]
+					if len(owner) == 0 || exported[owner] {
+						continue
+					}
+					exported[owner] = true
+					fmt.Printf("- %s\n", owner)
+					object, err := kubernetes.WorkloadObject(namespace, owner)
+					if err != nil {
+						fmt.Printf("  %v %v\n", aurora.Yellow("WARNING:"), err)
+						continue
+					}
+					manifest, err := manifestYaml(object, true)
+					if err != nil {
+						return err
+					}
+					if err = bundle.add(fmt.Sprintf("workloads/%s.yaml", strings.Replace(owner, "/", "-", 1)), manifest); err != nil {
+						return err
+					}
+				}
+
+				if err := bundle.close(); err != nil {
+					return fmt.Errorf("could not write %s: %w", filename, err)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Exported to %v.\n", aurora.Green(filename))
+			return nil
+		},
+	}
+
+	logsExportCommand.Flags().StringVarP(&filename, "file", "f", "", "the tar.gz to write (default: logs-<namespace>-<time>.tar.gz)")
+	logsExportCommand.Flags().StringVar(&since, "since", "", "only export log lines newer than a duration (like 1h) or a time (like 2021-03-01T10:00:00Z)")
+
+	return logsExportCommand
+}
+
+// writeFileOnSuccess lets write fill a temporary file next to filename, which is renamed to filename only if
+// writing succeeds; otherwise it is removed, so that no truncated file is left behind.
+func writeFileOnSuccess(filename string, write func(file *os.File) error) error {
+	file, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("could not create %s: %w", filename, err)
+	}
+	err = write(file)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("could not write %s: %w", filename, closeErr)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+// exportPod adds the pod, its events and the logs of its containers to the bundle.
+func exportPod(bundle *tarBundle, namespace string, pod *clientV1.Pod, events []clientV1.Event, since time.Time) error {
+	directory := "pods/" + pod.Name + "/"
+
+	// like the workloads, but keeping the status
+	cleanedPod := pod.DeepCopy()
+	for key := range cleanedPod.Annotations {
+		if restore.IsCleanedAnnotation(key) {
+			delete(cleanedPod.Annotations, key)
+		}
+	}
+	object, err := kubernetes.PodObject(cleanedPod)
+	if err != nil {
+		return err
+	}
+	manifest, err := manifestYaml(object, false)
+	if err != nil {
+		return err
+	}
+	if err = bundle.add(directory+"pod.yaml", manifest); err != nil {
+		return err
+	}
+
+	podEvents := make([]clientV1.Event, 0)
+	for _, event := range events {
+		if event.InvolvedObject.Kind == "Pod" && event.InvolvedObject.Name == pod.Name {
+			podEvents = append(podEvents, event)
+		}
+	}
+	if err = bundle.add(directory+"events.txt", formatEvents(podEvents)); err != nil {
+		return err
+	}
+
+	statuses := append(append([]clientV1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if status.State.Waiting != nil && status.RestartCount == 0 {
+			// never started, so there is no log
+			continue
+		}
+		if status.State.Waiting == nil {
+			if err = exportContainerLog(bundle, namespace, pod.Name, status.Name, false, since, directory+status.Name+".log"); err != nil {
+				return err
+			}
+		}
+		if status.RestartCount > 0 {
+			if err = exportContainerLog(bundle, namespace, pod.Name, status.Name, true, since, directory+status.Name+".previous.log"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// exportContainerLog adds the log to the bundle; a log which cannot be fetched is only reported.
+func exportContainerLog(bundle *tarBundle, namespace, podName, containerName string, previous bool, since time.Time, name string) error {
+	log, err := kubernetes.ContainerLog(namespace, podName, containerName, previous, since)
+	if err != nil {
+		fmt.Printf("  %v the log of container %s could not be fetched: %v\n", aurora.Yellow("WARNING:"), containerName, err)
+		return nil
+	}
+	return bundle.add(name, log)
+}
+
+// manifestYaml converts the object to YAML; with clean, like sku restore clean-manifests does.
+func manifestYaml(object runtime.Object, clean bool) ([]byte, error) {
+	// via JSON, to respect the json tags of the Kubernetes types
+	encoded, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+	if clean {
+		var manifest map[string]interface{}
+		if err = yaml.Unmarshal(encoded, &manifest); err != nil {
+			return nil, err
+		}
+		return yaml.Marshal(restore.CleanManifest(manifest))
+	}
+	// keeps the order of the fields
+	var manifest yaml.MapSlice
+	if err = yaml.Unmarshal(encoded, &manifest); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(manifest)
+}
+
+func formatEvents(events []clientV1.Event) []byte {
+	var formatted strings.Builder
+	for _, event := range events {
+		count := ""
+		if event.Count > 1 {
+			count = fmt.Sprintf(" (x%d)", event.Count)
+		}
+		formatted.WriteString(fmt.Sprintf("%s  %-7s  %-20s  %s/%s%s  %s\n",
+			kubernetes.EventTime(&event).Format(time.RFC3339), event.Type, event.Reason,
+			event.InvolvedObject.Kind, event.InvolvedObject.Name, count, event.Message))
+	}
+	return []byte(formatted.String())
+}
+
+// tarBundle writes files into a directory of a tar.gz.
+type tarBundle struct {
+	gzipWriter *gzip.Writer
+	tarWriter  *tar.Writer
+	directory  string
+	modTime    time.Time
+}
+
+func newTarBundle(file *os.File, directory string) *tarBundle {
+	gzipWriter := gzip.NewWriter(file)
+	return &tarBundle{
+		gzipWriter: gzipWriter,
+		tarWriter:  tar.NewWriter(gzipWriter),
+		directory:  directory,
+		modTime:    time.Now(),
+	}
+}
+
+func (b *tarBundle) add(name string, content []byte) error {
+	err := b.tarWriter.WriteHeader(&tar.Header{
+		Name:    path.Join(b.directory, name),
+		Mode:    0644,
+		Size:    int64(len(content)),
+		ModTime: b.modTime,
+	})
+	if err != nil {
+		return fmt.Errorf("could not add %s: %w", name, err)
+	}
+	if _, err = b.tarWriter.Write(content); err != nil {
+		return fmt.Errorf("could not add %s: %w", name, err)
+	}
+	return nil
+}
+
+func (b *tarBundle) close() error {
+	if err := b.tarWriter.Close(); err != nil {
+		return err
+	}
+	return b.gzipWriter.Close()
+}
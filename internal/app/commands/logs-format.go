@@ -0,0 +1,229 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/sandstorm/sku/pkg/kubernetes"
+)
+
+// output formats of sku logs (--output)
+const (
+	logOutputText = ""
+	logOutputRaw  = "raw"
+	logOutputJson = "json"
+	// logOutputPretty renders JSON log lines (--json)
+	logOutputPretty = "pretty"
+)
+
+// field names used by common JSON loggers (e.g. Monolog, pino, zap, logrus, ECS), in order of preference
+var (
+	jsonLogLevelFields   = []string{"level", "severity", "lvl", "level_name", "levelname", "log.level"}
+	jsonLogMessageFields = []string{"message", "msg", "@message", "text"}
+)
+
+// pino logs numeric levels
+var numericLogLevels = map[float64]string{10: "trace", 20: "debug", 30: "info", 40: "warn", 50: "error", 60: "fatal"}
+
+// logPrefixColors are assigned to the pods in turn.
+var logPrefixColors = []aurora.Color{aurora.GreenFg, aurora.CyanFg, aurora.MagentaFg, aurora.YellowFg, aurora.BlueFg, aurora.RedFg}
+
+// logPrinter filters and prints log lines.
+type logPrinter struct {
+	format string
+	// prefix lines with pod and container (when showing the logs of multiple pods)
+	prefix  bool
+	fields  []string
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+
+	podColors map[string]aurora.Color
+}
+
+func newLogPrinter(format string, prefix bool, fields, include, exclude []string) (*logPrinter, error) {
+	switch format {
+	case logOutputText, logOutputRaw, logOutputJson, logOutputPretty:
+	default:
+		return nil, fmt.Errorf("unknown output format %s; use raw or json", format)
+	}
+	printer := &logPrinter{format: format, prefix: prefix, fields: fields, podColors: make(map[string]aurora.Color)}
+	var err error
+	if printer.include, err = compilePatterns(include); err != nil {
+		return nil, fmt.Errorf("invalid --include: %w", err)
+	}
+	if printer.exclude, err = compilePatterns(exclude); err != nil {
+		return nil, fmt.Errorf("invalid --exclude: %w", err)
+	}
+	return printer, nil
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		expression, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, expression)
+	}
+	return compiled, nil
+}
+
+// matches returns true if the text matches one of the include patterns (if any), and none of the exclude patterns.
+func (p *logPrinter) matches(text string) bool {
+	for _, expression := range p.exclude {
+		if expression.MatchString(text) {
+			return false
+		}
+	}
+	if len(p.include) == 0 {
+		return true
+	}
+	for _, expression := range p.include {
+		if expression.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *logPrinter) print(line kubernetes.LogLine) {
+	if !p.matches(line.Text) {
+		return
+	}
+
+	switch p.format {
+	case logOutputRaw:
+		fmt.Println(line.Text)
+	case logOutputJson:
+		fmt.Println(logLineAsJson(line))
+	case logOutputPretty:
+		fmt.Printf("%s%v %s\n", p.prefixOf(line), aurora.Faint(line.Time.Local().Format("15:04:05")), renderJsonLogLine(line.Text, p.fields))
+	default:
+		fmt.Printf("%s%s\n", p.prefixOf(line), line.Text)
+	}
+}
+
+func (p *logPrinter) prefixOf(line kubernetes.LogLine) string {
+	if !p.prefix {
+		return ""
+	}
+	color, found := p.podColors[line.Pod]
+	if !found {
+		color = logPrefixColors[len(p.podColors)%len(logPrefixColors)]
+		p.podColors[line.Pod] = color
+	}
+	return aurora.Colorize(line.Pod+"/"+line.Container, color).String() + " "
+}
+
+// logLineAsJson wraps the line into an object for jq; JSON log lines are embedded as they are.
+func logLineAsJson(line kubernetes.LogLine) string {
+	var log interface{} = line.Text
+	if strings.HasPrefix(line.Text, "{") && json.Valid([]byte(line.Text)) {
+		log = json.RawMessage(line.Text)
+	}
+	encoded, err := json.Marshal(struct {
+		Pod       string      `json:"pod"`
+		Container string      `json:"container"`
+		Time      string      `json:"time"`
+		Log       interface{} `json:"log"`
+	}{line.Pod, line.Container, line.Time.Format(time.RFC3339Nano), log})
+	if err != nil {
+		// cannot happen for valid JSON
+		return line.Text
+	}
+	return string(encoded)
+}
+
+// renderJsonLogLine prints the level and message of a JSON log line, followed by the given fields;
+// other lines are returned as they are.
+func renderJsonLogLine(text string, fields []string) string {
+	var entry map[string]interface{}
+	if !strings.HasPrefix(text, "{") || json.Unmarshal([]byte(text), &entry) != nil {
+		return text
+	}
+
+	level := ""
+	if value, found := lookupJsonField(entry, jsonLogLevelFields); found {
+		level = formatLogLevel(value)
+	}
+	message := ""
+	if value, found := lookupJsonField(entry, jsonLogMessageFields); found {
+		message = formatJsonValue(value)
+	}
+
+	var rendered strings.Builder
+	if len(level) > 0 {
+		rendered.WriteString(colorizeLogLevel(level).String())
+		rendered.WriteString(" ")
+	}
+	rendered.WriteString(message)
+	for _, field := range fields {
+		if value, found := lookupJsonField(entry, []string{field}); found {
+			rendered.WriteString(fmt.Sprintf(" %v=%s", aurora.Cyan(field), formatJsonValue(value)))
+		}
+	}
+	return rendered.String()
+}
+
+// lookupJsonField returns the value of the first of the fields present; nested fields are given as "a.b".
+func lookupJsonField(entry map[string]interface{}, fields []string) (interface{}, bool) {
+	for _, field := range fields {
+		if value, found := entry[field]; found {
+			return value, true
+		}
+		var current interface{} = entry
+		found := true
+		for _, part := range strings.Split(field, ".") {
+			object, isObject := current.(map[string]interface{})
+			if !isObject {
+				found = false
+				break
+			}
+			if current, found = object[part]; !found {
+				break
+			}
+		}
+		if found {
+			return current, true
+		}
+	}
+	return nil, false
+}
+
+func formatJsonValue(value interface{}) string {
+	if text, isString := value.(string); isString {
+		return text
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+func formatLogLevel(value interface{}) string {
+	if number, isNumber := value.(float64); isNumber {
+		if level, found := numericLogLevels[number]; found {
+			value = level
+		}
+	}
+	return fmt.Sprintf("%-5s", strings.ToUpper(formatJsonValue(value)))
+}
+
+func colorizeLogLevel(level string) aurora.Value {
+	switch strings.TrimSpace(level) {
+	case "ERROR", "ERR", "FATAL", "CRITICAL", "CRIT", "ALERT", "EMERGENCY", "PANIC":
+		return aurora.Red(level)
+	case "WARN", "WARNING":
+		return aurora.Yellow(level)
+	case "INFO", "NOTICE":
+		return aurora.Green(level)
+	default:
+		return aurora.Faint(level)
+	}
+}
@@ -18,16 +18,17 @@ import (
 	"context"
 	"fmt"
 	"github.com/logrusorgru/aurora"
+	"github.com/sandstorm/sku/pkg/config"
 	"github.com/sandstorm/sku/pkg/kubernetes"
 	"github.com/sandstorm/sku/pkg/utility"
 	"github.com/spf13/cobra"
 	clientV1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"os"
-	"syscall"
+	"time"
 )
 
-// logsCmd represents the enter command
+// logsCmd represents the logs command
 var logsCmd = &cobra.Command{
 	Use:   "logs",
 	Short: "Show logs in a Kubernetes container",
@@ -38,6 +39,14 @@ To select the pods you want to get the logs for, you'll see a choice list.
 Optionally, you can restrict the pod list by specifying a label
 selector.
 
+With --all, the logs of all pods matching the label selector are streamed at once, each line
+prefixed with pod and container. Pods started (and containers restarted) meanwhile are picked
+up automatically. --container restricts the logs to one container.
+
+--since and --until limit the logs to a time window (a duration like 1h, or a time like
+2021-03-01T10:00:00Z); --tail to the last lines. --include and --exclude filter the lines by
+regular expressions. --json renders JSON log lines as level and message (plus --fields);
+--output raw|json prints plain lines or one JSON object per line, e.g. for jq.
 `,
 	Example: `
 # get presented a choice list which logs to show
@@ -48,55 +57,193 @@ selector.
 	sku logs app=foo
 	sku logs app=foo,component=app
 
+# follow all replicas of a deployment at once
+	sku logs app=foo --all
+	sku logs app=foo --all --container php
+
+# errors of the last hour, without health checks
+	sku logs app=foo --all --since 1h --include "(?i)error" --exclude healthz
+
+# render JSON logs, or pipe them into jq
+	sku logs app=foo --all --json --fields requestId,user.id
+	sku logs app=foo --all --follow=false -o json | jq 'select(.log.level == "error")'
+
+# the logs of the crashed instance of a restarting container
+	sku logs app=foo --previous
 `,
 	Args: cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		currentContext := kubernetes.KubernetesApiConfig().CurrentContext
-		k8sContextDefinition := kubernetes.KubernetesApiConfig().Contexts[currentContext]
-		labelSelector := ""
+	RunE: func(cmd *cobra.Command, args []string) error {
+		currentContext := kubernetes.CurrentContextName()
+		namespace := kubernetes.CurrentNamespace()
+		labelSelector := setting("logs."+config.KeySelector, "")
 		if len(args) == 1 {
 			labelSelector = args[0]
-			fmt.Printf("Listing pods with label %v in namespace %v in k8sContextDefinition %v.\n", aurora.Green(labelSelector), aurora.Green(k8sContextDefinition.Namespace), aurora.Green(currentContext))
+		}
+
+		options, err := logStreamOptions()
+		if err != nil {
+			return err
+		}
+		format := logsOutput
+		if logsJson {
+			if len(format) > 0 {
+				return fmt.Errorf("--json and --output cannot be combined")
+			}
+			format = logOutputPretty
+		}
+		printer, err := newLogPrinter(format, logsAllPods, logsFields, logsInclude, logsExclude)
+		if err != nil {
+			return err
+		}
+
+		if logsAllPods {
+			if len(kubernetes.Preselected.Pod) > 0 {
+				labelSelector = kubernetes.Preselected.Pod
+			}
+			if len(labelSelector) > 0 {
+				fmt.Fprintf(os.Stderr, "Showing the logs of all pods with label %v in namespace %v in %v.\n", aurora.Green(labelSelector), aurora.Green(namespace), aurora.Green(currentContext))
+			} else {
+				fmt.Fprintf(os.Stderr, "Showing the logs of all pods in namespace %v in %v.\n", aurora.Green(namespace), aurora.Green(currentContext))
+			}
+			options.LabelSelector = labelSelector
+			options.Container = kubernetes.Preselected.Container
+			return kubernetes.StreamLogs(namespace, options, printer.print)
+		}
+
+		if len(labelSelector) > 0 {
+			fmt.Fprintf(os.Stderr, "Listing pods with label %v in namespace %v in context %v.\n", aurora.Green(labelSelector), aurora.Green(namespace), aurora.Green(currentContext))
 		} else {
-			fmt.Printf("Listing pods in namespace %v in k8sContextDefinition %v.\n", aurora.Green(k8sContextDefinition.Namespace), aurora.Green(currentContext))
+			fmt.Fprintf(os.Stderr, "Listing pods in namespace %v in context %v.\n", aurora.Green(namespace), aurora.Green(currentContext))
 		}
 
-		podList, _ := kubernetes.KubernetesClientset().CoreV1().Pods(k8sContextDefinition.Namespace).List(context.Background(), v1.ListOptions{
+		if len(kubernetes.Preselected.Pod) == 0 && len(labelSelector) > 0 && utility.NonInteractive {
+			// in non-interactive mode, the label selector argument acts like --pod
+			kubernetes.Preselected.Pod = labelSelector
+		}
+		if len(kubernetes.Preselected.Pod) > 0 {
+			labelSelector = ""
+		}
+
+		podList, err := kubernetes.KubernetesClientset().CoreV1().Pods(namespace).List(context.Background(), v1.ListOptions{
 			LabelSelector: labelSelector,
 		})
+		if err != nil {
+			return fmt.Errorf("pods could not be listed: %w", err)
+		}
 
 		for i, pod := range podList.Items {
 			if pod.Status.Phase == clientV1.PodRunning {
-				fmt.Printf("%d: %v - %v \n", i, aurora.Green(pod.Name), pod.Labels)
+				fmt.Fprintf(os.Stderr, "%d: %v - %v \n", i, aurora.Green(pod.Name), pod.Labels)
 			} else {
-				fmt.Printf("%d: %v - %v \n", i, pod.Name, pod.Labels)
+				fmt.Fprintf(os.Stderr, "%d: %v - %v \n", i, pod.Name, pod.Labels)
 			}
 		}
 
-		i := utility.GetNumberChoice()
-
-		containerName := ""
-		if len(podList.Items[i].Spec.Containers) > 1 {
-			fmt.Printf("Which container?.\n")
-			for ci, c := range podList.Items[i].Spec.Containers {
-				fmt.Printf("%d: %v\n", ci, aurora.Green(c.Name))
+		var i int
+		if len(kubernetes.Preselected.Pod) > 0 {
+			podName, err := kubernetes.SelectPod("")
+			if err != nil {
+				return err
+			}
+			i = indexOfPod(podList, podName)
+		} else {
+			i, err = utility.GetNumberChoice("pass --pod with a pod name or label selector")
+			if err != nil {
+				return fmt.Errorf("no pod selected: %w", err)
 			}
-			ci := utility.GetNumberChoice()
+		}
+		if i < 0 || i >= len(podList.Items) {
+			return fmt.Errorf("%w: pod %d does not exist", kubernetes.ErrPromptAborted, i)
+		}
 
-			containerName = podList.Items[i].Spec.Containers[ci].Name
+		containerName, err := kubernetes.SelectContainer(&podList.Items[i])
+		if err != nil {
+			return fmt.Errorf("no container selected: %w", err)
 		}
 
-		fmt.Printf("Showing Logs to %v %s in %v:\n", aurora.Green(podList.Items[i].Name), containerName, aurora.Green(currentContext))
+		fmt.Fprintf(os.Stderr, "Showing Logs to %v %s in %v:\n", aurora.Green(podList.Items[i].Name), containerName, aurora.Green(currentContext))
 
-		kubectlArgs := []string{"kubectl", "logs", "-f"}
-		if containerName != "" {
-			kubectlArgs = append(kubectlArgs, "-c", containerName)
-		}
-		kubectlArgs = append(kubectlArgs, podList.Items[i].Name)
-		syscall.Exec("/usr/local/bin/kubectl", kubectlArgs, os.Environ())
+		options.Pod = podList.Items[i].Name
+		options.Container = containerName
+		return kubernetes.StreamLogs(namespace, options, printer.print)
 	},
 }
 
+var (
+	logsAllPods  bool
+	logsFollow   bool
+	logsPrevious bool
+	logsSince    string
+	logsUntil    string
+	logsTail     int64
+	logsInclude  []string
+	logsExclude  []string
+	logsJson     bool
+	logsFields   []string
+	logsOutput   string
+)
+
+// logStreamOptions builds the options from the flags.
+func logStreamOptions() (kubernetes.LogStreamOptions, error) {
+	options := kubernetes.LogStreamOptions{
+		Follow:    logsFollow,
+		Previous:  logsPrevious,
+		TailLines: logsTail,
+	}
+	var err error
+	if options.Since, err = parseLogTime(logsSince); err != nil {
+		return options, fmt.Errorf("invalid --since: %w", err)
+	}
+	if options.Until, err = parseLogTime(logsUntil); err != nil {
+		return options, fmt.Errorf("invalid --until: %w", err)
+	}
+	if !options.Until.IsZero() {
+		// the logs up to a point in time are not followed
+		options.Follow = false
+	}
+	return options, nil
+}
+
+// parseLogTime parses a duration before now (e.g. "1h30m") or a time (RFC3339, e.g. "2021-03-01T10:00:00Z").
+func parseLogTime(value string) (time.Time, error) {
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s is neither a duration (like 1h) nor a time (like 2021-03-01T10:00:00Z)", value)
+	}
+	return parsed, nil
+}
+
+// indexOfPod returns the index of the named pod in the pod list, or -1.
+func indexOfPod(podList *clientV1.PodList, podName string) int {
+	for i, pod := range podList.Items {
+		if pod.Name == podName {
+			return i
+		}
+	}
+	return -1
+}
+
 func init() {
+	logsCmd.Flags().BoolVar(&logsAllPods, "all", false, "stream the logs of all matching pods (and all their containers) at once")
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", true, "keep streaming new log lines")
+	logsCmd.Flags().BoolVar(&logsPrevious, "previous", false, "show the logs of the previous instance of restarted containers")
+	logsCmd.Flags().StringVar(&logsSince, "since", "", "only show lines newer than a duration (like 1h) or a time (like 2021-03-01T10:00:00Z)")
+	logsCmd.Flags().StringVar(&logsUntil, "until", "", "only show lines older than a duration (like 10m) or a time; implies --follow=false")
+	logsCmd.Flags().Int64Var(&logsTail, "tail", -1, "only show the last lines of each container; -1 for all")
+	logsCmd.Flags().StringArrayVar(&logsInclude, "include", nil, "only show lines matching the regular expression; can be given multiple times")
+	logsCmd.Flags().StringArrayVar(&logsExclude, "exclude", nil, "hide lines matching the regular expression; can be given multiple times")
+	logsCmd.Flags().BoolVar(&logsJson, "json", false, "render JSON log lines as level and message")
+	logsCmd.Flags().StringSliceVar(&logsFields, "fields", nil, "with --json, the fields to show after the message (nested fields as a.b)")
+	logsCmd.Flags().StringVarP(&logsOutput, "output", "o", "", "raw: the plain lines; json: one JSON object per line (with pod, container, time and log)")
+	logsCmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{logOutputRaw, logOutputJson}, cobra.ShellCompDirectiveNoFileComp
+	})
+	logsCmd.AddCommand(BuildLogsExportCommand())
 	RootCmd.AddCommand(logsCmd)
 }
@@ -43,28 +43,45 @@ List and switch kubernetes namespaces.`,
 		if len(args) != 0 {
 			return nil, cobra.ShellCompDirectiveNoFileComp
 		}
-		namespaceList, _ := kubernetes.KubernetesClientset().CoreV1().Namespaces().List(context.Background(), meta_v1.ListOptions{})
+		if err := initKubernetes(); err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		namespaceList, err := kubernetes.KubernetesClientset().CoreV1().Namespaces().List(context.Background(), meta_v1.ListOptions{})
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
 		return kubernetes.NamespacesToString(namespaceList), cobra.ShellCompDirectiveNoFileComp
 	},
-	Run: func(cmd *cobra.Command, args []string) {
-		namespaceList, _ := kubernetes.KubernetesClientset().CoreV1().Namespaces().List(context.Background(), meta_v1.ListOptions{})
+	RunE: func(cmd *cobra.Command, args []string) error {
+		namespaceList, err := kubernetes.KubernetesClientset().CoreV1().Namespaces().List(context.Background(), meta_v1.ListOptions{})
+		if err != nil {
+			return fmt.Errorf("namespaces could not be listed: %w", err)
+		}
 
 		if len(args) == 0 {
 			fmt.Printf("Namespaces: \n")
 			kubernetes.PrintExistingNamespaces(namespaceList)
 		} else {
 			config := kubernetes.KubernetesApiConfig()
-			currentContext := config.CurrentContext
-			context := kubernetes.KubernetesApiConfig().Contexts[currentContext]
+			currentContext := kubernetes.CurrentContextName()
+			context := config.Contexts[currentContext]
 			newNamespace := args[0]
 
-			kubernetes.EnsureNamespaceExists(newNamespace, namespaceList)
+			if err := kubernetes.EnsureNamespaceExists(newNamespace, namespaceList); err != nil {
+				fmt.Printf("%v\n", aurora.Red("Namespace not found; use one of the list below:"))
+				kubernetes.PrintExistingNamespaces(namespaceList)
+				return err
+			}
 
 			context.Namespace = newNamespace
-			clientcmd.ModifyConfig(clientcmd.NewDefaultPathOptions(), *config, false)
+			if err := clientcmd.ModifyConfig(clientcmd.NewDefaultPathOptions(), *config, false); err != nil {
+				return fmt.Errorf("could not write the kubeconfig: %w", err)
+			}
 
-			fmt.Printf("Switched to namespace %v in context %v.\n", aurora.Green(newNamespace), aurora.Green(currentContext))
+			fmt.Printf("Switched to namespace %v in context %v%s.\n", aurora.Green(newNamespace), aurora.Green(currentContext), sessionScopeHint())
 		}
+		warnIfProjectDisagrees()
+		return nil
 	},
 }
 
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/logrusorgru/aurora/v3"
+	"github.com/sandstorm/sku/pkg/database"
+	"github.com/sandstorm/sku/pkg/kubernetes"
+	"github.com/spf13/cobra"
+)
+
+func BuildProxyContainersCommand() *cobra.Command {
+	allNamespaces := false
+	onlyStale := false
+
+	var proxyContainersCommand = &cobra.Command{
+		Use:   "proxy-containers",
+		Short: "List the socat debug containers sku added to pods for database connections",
+		Long: `
+sku mysql / sku postgres connect to the database through a socat debug container
+(named sku-socat-...) which is added to a running pod. Running proxy containers are
+re-used by later invocations.
+
+Kubernetes does not allow removing debug containers from a pod; they are cleaned up
+when the pod is re-created (e.g. on the next deployment).
+`,
+		Example: `
+# list all proxy containers in the current namespace
+	sku proxy-containers
+
+# only list terminated proxy containers in all namespaces
+	sku proxy-containers --stale --all-namespaces
+`,
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			namespace := ""
+			if !allNamespaces {
+				namespace = kubernetes.CurrentNamespace()
+			}
+
+			proxyContainers, err := database.ListProxyContainers(namespace)
+			if err != nil {
+				return err
+			}
+
+			found := false
+			for _, proxyContainer := range proxyContainers {
+				if onlyStale && proxyContainer.Running {
+					continue
+				}
+				found = true
+
+				state := aurora.Red(proxyContainer.State)
+				if proxyContainer.Running {
+					state = aurora.Green(proxyContainer.State)
+				}
+				fmt.Printf("%s/%s %s -> %s (%s)\n", proxyContainer.Namespace, aurora.Bold(proxyContainer.PodName), proxyContainer.ContainerName, proxyContainer.Target, state)
+			}
+
+			if !found {
+				fmt.Println("No proxy containers found.")
+			}
+			return nil
+		},
+	}
+
+	proxyContainersCommand.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "list proxy containers of all namespaces")
+	proxyContainersCommand.Flags().BoolVarP(&onlyStale, "stale", "", false, "only list proxy containers which are not running anymore")
+
+	return proxyContainersCommand
+}
+
+func init() {
+	RootCmd.AddCommand(BuildProxyContainersCommand())
+}
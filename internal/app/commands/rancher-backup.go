@@ -38,8 +38,8 @@ NOTE: it is *NOT* possible to directly import the Rancher config which has been
 	sku rancher-backup --url https://your-rancher-server.de/v3 --token BEARER-TOKEN-HERE --output ./backup-directory
 `,
 	Args: cobra.ExactArgs(0),
-	Run: func(cmd *cobra.Command, args []string) {
-		rancher.RunBackup(apiEndpointUrl, token, outputDirectory)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return rancher.RunBackup(apiEndpointUrl, token, outputDirectory)
 	},
 }
 
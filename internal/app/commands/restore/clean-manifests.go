@@ -265,6 +265,16 @@ func findFirst(kubeFiles []*KubeFile, callback func(*KubeFile) bool) (*KubeFile,
 	return nil, false
 }
 
+// CleanManifest applies the cleanup of clean-manifests to a single manifest, e.g. fetched from the cluster: of the
+// metadata, only name, namespace, labels and annotations are kept (so managedFields, uid, ... are removed), the
+// last-applied-configuration (which may contain secrets) and the status are removed.
+func CleanManifest(manifest map[string]interface{}) map[string]interface{} {
+	kubeFile := &KubeFile{FullKubeFile: manifest}
+	kubeFile.Parsed.Kind, _ = manifest["kind"].(string)
+	kubeFiles := cleanManifestsTypeSpecific(cleanManifests([]*KubeFile{kubeFile}))
+	return kubeFiles[0].FullKubeFile
+}
+
 func cleanManifests(kubeFiles []*KubeFile) []*KubeFile {
 	for _, kubeFile := range kubeFiles {
 
@@ -311,8 +321,7 @@ func cleanAnnotations(metadata map[interface{}]interface{}) map[interface{}]inte
 	}
 
 	for k := range annotations {
-		if k == "kubectl.kubernetes.io/last-applied-configuration" ||
-			k == "deployment.kubernetes.io/revision" {
+		if key, ok := k.(string); ok && IsCleanedAnnotation(key) {
 			delete(annotations, k)
 		}
 	}
@@ -326,6 +335,12 @@ func cleanAnnotations(metadata map[interface{}]interface{}) map[interface{}]inte
 	return metadata
 }
 
+// IsCleanedAnnotation returns true for the annotations removed by clean-manifests.
+func IsCleanedAnnotation(key string) bool {
+	return key == "kubectl.kubernetes.io/last-applied-configuration" ||
+		key == "deployment.kubernetes.io/revision"
+}
+
 func cleanLabels(metadata map[interface{}]interface{}) map[interface{}]interface{} {
 	res, ok := metadata["labels"]
 	if !ok {
@@ -4,12 +4,10 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/logrusorgru/aurora"
-	"github.com/manifoldco/promptui"
 	"github.com/sandstorm/sku/pkg/database"
 	"github.com/sandstorm/sku/pkg/kubernetes"
 	"github.com/spf13/cobra"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"strings"
@@ -17,10 +15,8 @@ import (
 )
 
 func BuildMariadbCommand() *cobra.Command {
-	dbHost := ""
-	dbName := ""
-	dbUser := ""
-	dbPassword := ""
+	credentialExpressions := database.CredentialExpressions{}
+	proxyStrategy := ""
 	restoreBackupPath := ""
 
 	mariadbCommand := &cobra.Command{
@@ -32,141 +28,134 @@ func BuildMariadbCommand() *cobra.Command {
 `,
 
 		Args: cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			// we wrap the full code in a closure, and directly execute it,
-			// so that we can run defer statements and they get executed before exiting
-			resultCode := (func() int {
-				//=================================
-				// Preparation (Parameter Parsing)
-				//=================================
-				fmt.Println(aurora.Bold("Restoring MariaDB to the Kubernetes cluster"))
-				fmt.Println(aurora.Bold("==========================================="))
-				fmt.Println("This is an interactive wizard guiding you through restoring a MySQL/MariaDB database.")
-				fmt.Println("Before any destructive operation, you'll be asked whether you want to continue.")
-				fmt.Println("")
-				fmt.Println("")
-
-				sqlFileName := args[0]
-				if len(sqlFileName) == 0 {
-					fmt.Printf("%s the SQL file must be given as parameter\n", aurora.Red("ERROR:"))
-					return 1
-				}
-
-				fileStats, err := os.Stat(sqlFileName)
-				if err != nil {
-					fmt.Printf("%s SQL File %s not found:\n    %s\n", aurora.Red("ERROR:"), aurora.Bold(sqlFileName), err)
-					return 1
-				}
-				if fileStats.IsDir() {
-					fmt.Printf("%s SQL File %s is a directory, but needs to be a file\n", aurora.Red("ERROR:"), aurora.Bold(sqlFileName))
-					return 1
-				}
-
-				currentContext := kubernetes.KubernetesApiConfig().CurrentContext
-				k8sContextDefinition := kubernetes.KubernetesApiConfig().Contexts[currentContext]
-
-				dbHost = kubernetes.EvalScriptParameter(dbHost)
-				dbName = kubernetes.EvalScriptParameter(dbName)
-				dbUser = kubernetes.EvalScriptParameter(dbUser)
-				dbPassword = kubernetes.EvalScriptParameter(dbPassword)
-
-				localDbProxyPort, db, kubectlPortForward, err := database.MysqlDatabaseConnectionThroughPod(dbHost, dbName, dbUser, dbPassword)
-				if err != nil {
-					fmt.Println(err)
-					return 1
-				}
-				defer kubectlPortForward.Process.Kill()
-				defer db.Close()
-
-				//=================================
-				// Create SQL Backup
-				//=================================
-				fmt.Println("")
-				fmt.Println("")
-				fmt.Printf("4) %s and inport the SQL dump", aurora.Bold("Clear all data"))
-				fmt.Println("")
-				fmt.Println("   After doing an SQL dump, the database will be cleared, and the given data from the backup will be imported.")
-				fmt.Println("")
-
-				restoreBackupPath = path.Join(restoreBackupPath, time.Now().Format("01-02-2006-15-04-05")+"__"+k8sContextDefinition.Namespace)
-				if err = os.MkdirAll(restoreBackupPath, os.ModePerm); err != nil {
-					fmt.Printf("%s could not create %s:\n    %v\n", aurora.Red("ERROR:"), restoreBackupPath, err)
-				}
-
-				mysqlDump := exec.Command(
-					"mysqldump",
-					"--host=127.0.0.1",
-					fmt.Sprintf("--port=%d", localDbProxyPort),
-					fmt.Sprintf("--user=%s", dbUser),
-					fmt.Sprintf("--password=%s", dbPassword),
-					fmt.Sprintf("--result-file=%s/backup.sql", restoreBackupPath),
-					dbName,
-				)
-				mysqlDump.Stdout = os.Stdout
-				mysqlDump.Stderr = os.Stderr
-
-				fmt.Println("- Starting to execute SQL backup")
-				err = mysqlDump.Run()
-				if err != nil {
-					fmt.Printf("%s could not run mysqldump:\n    mysqldump %v\n    %v\n", aurora.Red("ERROR:"), strings.Join(mysqlDump.Args, " "), err)
-					return 1
-				}
-				fmt.Println("- Finished to execute SQL backup")
-
-				//=================================
-				// Empty database
-				//=================================
-				prompt := promptui.Prompt{
-					Label:     aurora.Bold("CLEAR THE DATABASE and IMPORT from backup?"),
-					IsConfirm: true,
-				}
-				_, err = prompt.Run()
-				if err != nil {
-					fmt.Printf("user aborted.\n")
-					return 1
-				}
-
-				err = emptyDatabase(db, dbName)
-				if err != nil {
-					fmt.Printf("%s could not empty DB:\n    %v\n", aurora.Red("ERROR:"), err)
-					return 1
-				}
-
-				//=================================
-				// Import into database
-				//=================================
-				mysqlImport := exec.Command(
-					"bash",
-					"-c",
-					fmt.Sprintf(
-						"mysql --host=127.0.0.1 --port=%d --user=%s --password=%s %s < %s",
-						localDbProxyPort,
-						dbUser,
-						dbPassword,
-						dbName,
-						sqlFileName,
-					),
-				)
-				mysqlImport.Stdout = os.Stdout
-				mysqlImport.Stderr = os.Stderr
-
-				fmt.Println("- Importing SQL ")
-				err = mysqlImport.Run()
-				if err != nil {
-					fmt.Printf("%s could not import DB:\n    Command executed: %s\n    Error: %v\n", aurora.Red("ERROR:"), mysqlImport.String(), err)
-					return 1
-				}
-
-				return 0
-			})()
-			os.Exit(resultCode)
+		RunE: func(cmd *cobra.Command, args []string) error {
+			//=================================
+			// Preparation (Parameter Parsing)
+			//=================================
+			fmt.Println(aurora.Bold("Restoring MariaDB to the Kubernetes cluster"))
+			fmt.Println(aurora.Bold("==========================================="))
+			fmt.Println("This is an interactive wizard guiding you through restoring a MySQL/MariaDB database.")
+			fmt.Println("Before any destructive operation, you'll be asked whether you want to continue.")
+			fmt.Println("")
+			fmt.Println("")
+
+			sqlFileName := args[0]
+			if len(sqlFileName) == 0 {
+				return fmt.Errorf("the SQL file must be given as parameter")
+			}
+
+			fileStats, err := os.Stat(sqlFileName)
+			if err != nil {
+				return fmt.Errorf("SQL File %s not found: %w", aurora.Bold(sqlFileName), err)
+			}
+			if fileStats.IsDir() {
+				return fmt.Errorf("SQL File %s is a directory, but needs to be a file", aurora.Bold(sqlFileName))
+			}
+
+			namespace := kubernetes.CurrentNamespace()
+
+			engine, _ := database.Engine("mysql")
+			parsedProxyStrategy, err := database.ParseProxyStrategy(proxyStrategy)
+			if err != nil {
+				return err
+			}
+			credentials, err := credentialExpressions.Evaluate(kubernetes.NewEvalContext())
+			if err != nil {
+				return err
+			}
+
+			connection, err := database.ConnectThroughPod(engine, credentials, parsedProxyStrategy, 0)
+			if err != nil {
+				return err
+			}
+			defer connection.Close()
+			db, err := connection.OpenSql()
+			if err != nil {
+				return fmt.Errorf("could not open SQL connection: %w", err)
+			}
+			defer db.Close()
+			endpoint := connection.Endpoint()
+
+			//=================================
+			// Create SQL Backup
+			//=================================
+			fmt.Println("")
+			fmt.Println("")
+			fmt.Printf("4) %s and inport the SQL dump", aurora.Bold("Clear all data"))
+			fmt.Println("")
+			fmt.Println("   After doing an SQL dump, the database will be cleared, and the given data from the backup will be imported.")
+			fmt.Println("")
+
+			restoreBackupPath = path.Join(restoreBackupPath, time.Now().Format("01-02-2006-15-04-05")+"__"+namespace)
+			if err = os.MkdirAll(restoreBackupPath, os.ModePerm); err != nil {
+				fmt.Printf("%s could not create %s:\n    %v\n", aurora.Red("ERROR:"), restoreBackupPath, err)
+			}
+
+			mysqlDump, err := engine.DumpCommand(endpoint, fmt.Sprintf("%s/backup.sql", restoreBackupPath))
+			if err != nil {
+				return fmt.Errorf("could not prepare the SQL backup: %w", err)
+			}
+			mysqlDump.Stdout = os.Stdout
+			mysqlDump.Stderr = os.Stderr
+
+			fmt.Println("- Starting to execute SQL backup")
+			err = mysqlDump.Run()
+			if err != nil {
+				return fmt.Errorf("could not run mysqldump (mysqldump %v): %w", strings.Join(mysqlDump.Args, " "), err)
+			}
+			fmt.Println("- Finished to execute SQL backup")
+
+			//=================================
+			// Empty database
+			//=================================
+			confirmed, err := kubernetes.ConfirmDestructive("CLEAR THE DATABASE and IMPORT from backup?")
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				return fmt.Errorf("%w by the user", kubernetes.ErrPromptAborted)
+			}
+
+			err = emptyDatabase(db, credentials.Name)
+			if err != nil {
+				return fmt.Errorf("could not empty DB: %w", err)
+			}
+
+			//=================================
+			// Import into database
+			//=================================
+			sqlFile, err := os.Open(sqlFileName)
+			if err != nil {
+				return fmt.Errorf("could not open SQL file: %w", err)
+			}
+			defer sqlFile.Close()
+
+			mysqlImport, err := engine.RestoreCommand(endpoint)
+			if err != nil {
+				return fmt.Errorf("could not prepare the import: %w", err)
+			}
+			mysqlImport.Stdin = sqlFile
+			mysqlImport.Stdout = os.Stdout
+			mysqlImport.Stderr = os.Stderr
+
+			fmt.Println("- Importing SQL ")
+			err = mysqlImport.Run()
+			if err != nil {
+				return fmt.Errorf("could not import DB (command executed: %s): %w", mysqlImport.String(), err)
+			}
+
+			return nil
 		},
 	}
 
-	mariadbCommand.Flags().StringVarP(&dbHost, "dbHost", "", "eval:configmap('db').DB_HOST", "filename that contains the configuration to apply")
-	mariadbCommand.Flags().StringVarP(&dbName, "dbName", "", "eval:configmap('db').DB_NAME", "filename that contains the configuration to apply")
-	mariadbCommand.Flags().StringVarP(&dbUser, "dbUser", "", "eval:configmap('db').DB_USER", "filename that contains the configuration to apply")
-	mariadbCommand.Flags().StringVarP(&dbPassword, "dbPassword", "", "eval:secret('db').DB_PASSWORD", "filename that contains the configuration to apply")
+	mariadbCommand.Flags().StringVarP(&credentialExpressions.Host, "dbHost", "", "eval:configmap('db').DB_HOST", "filename that contains the configuration to apply")
+	mariadbCommand.Flags().StringVarP(&credentialExpressions.Name, "dbName", "", "eval:configmap('db').DB_NAME", "filename that contains the configuration to apply")
+	mariadbCommand.Flags().StringVarP(&credentialExpressions.User, "dbUser", "", "eval:configmap('db').DB_USER", "filename that contains the configuration to apply")
+	mariadbCommand.Flags().StringVarP(&credentialExpressions.Password, "dbPassword", "", "eval:secret('db').DB_PASSWORD", "filename that contains the configuration to apply")
+	mariadbCommand.Flags().StringVarP(&proxyStrategy, "proxy-strategy", "", string(database.ProxyStrategyDebug), "how to reach the database from within the cluster: debug (debug container in a running pod), pod (dedicated proxy pod) or direct (port-forward to the selected pod)")
+	mariadbCommand.RegisterFlagCompletionFunc("proxy-strategy", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return database.ProxyStrategies, cobra.ShellCompDirectiveNoFileComp
+	})
 	userHomeDir, _ := os.UserHomeDir()
 	mariadbCommand.Flags().StringVarP(&restoreBackupPath, "restoreBackupPath", "", filepath.Join(userHomeDir, "src/k8s/restore-backups"), "filename that contains the configuration to apply")
 
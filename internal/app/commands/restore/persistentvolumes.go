@@ -5,8 +5,8 @@ import (
 	"fmt"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/logrusorgru/aurora"
-	"github.com/manifoldco/promptui"
 	"github.com/sandstorm/sku/pkg/kubernetes"
+	"github.com/sandstorm/sku/pkg/utility"
 	"github.com/sandstorm/sku/pkg/utility/wrapexec"
 	"github.com/spf13/cobra"
 	clientV1 "k8s.io/api/core/v1"
@@ -20,6 +20,8 @@ import (
 
 func BuildPersistentVolumesCommand() *cobra.Command {
 	restoreBackupPath := ""
+	// mount path => backup folder to replay there; to skip the prompt
+	chosenBackups := map[string]string{}
 
 	persistentVolumesCommand := &cobra.Command{
 		Use:   "persistentvolumes",
@@ -30,151 +32,149 @@ func BuildPersistentVolumesCommand() *cobra.Command {
 `,
 
 		Args: cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			// we wrap the full code in a closure, and directly execute it,
-			// so that we can run defer statements and they get executed before exiting
-			resultCode := (func() int {
-				//=================================
-				// Preparation (Parameter Parsing)
-				//=================================
-				fmt.Println(aurora.Bold("Restoring Persistent Volumes to the Kubernetes cluster"))
-				fmt.Println(aurora.Bold("======================================================"))
-				fmt.Println("This is an interactive wizard guiding you through restoring Persistent Volumes.")
-				fmt.Println("Before any destructive operation, you'll be asked whether you want to continue.")
-				fmt.Println("")
-				fmt.Println("")
-
-				persistentVolumesBackupFolder := args[0]
-				if len(persistentVolumesBackupFolder) == 0 {
-					fmt.Printf("%s the persistent volumes folder must be given as parameter\n", aurora.Red("ERROR:"))
-					return 1
-				}
-
-				fileStats, err := os.Stat(persistentVolumesBackupFolder)
-				if err != nil {
-					fmt.Printf("%s persistent volumes folder %s not found:\n    %s\n", aurora.Red("ERROR:"), aurora.Bold(persistentVolumesBackupFolder), err)
-					return 1
-				}
-				if !fileStats.IsDir() {
-					fmt.Printf("%s persistent volumes folder %s is a file, but needs to be a directory\n", aurora.Red("ERROR:"), aurora.Bold(persistentVolumesBackupFolder))
-					return 1
-				}
+		RunE: func(cmd *cobra.Command, args []string) error {
+			//=================================
+			// Preparation (Parameter Parsing)
+			//=================================
+			fmt.Println(aurora.Bold("Restoring Persistent Volumes to the Kubernetes cluster"))
+			fmt.Println(aurora.Bold("======================================================"))
+			fmt.Println("This is an interactive wizard guiding you through restoring Persistent Volumes.")
+			fmt.Println("Before any destructive operation, you'll be asked whether you want to continue.")
+			fmt.Println("")
+			fmt.Println("")
+
+			persistentVolumesBackupFolder := args[0]
+			if len(persistentVolumesBackupFolder) == 0 {
+				return fmt.Errorf("the persistent volumes folder must be given as parameter")
+			}
 
-				currentContext := kubernetes.KubernetesApiConfig().CurrentContext
-				k8sContextDefinition := kubernetes.KubernetesApiConfig().Contexts[currentContext]
+			fileStats, err := os.Stat(persistentVolumesBackupFolder)
+			if err != nil {
+				return fmt.Errorf("persistent volumes folder %s not found: %w", aurora.Bold(persistentVolumesBackupFolder), err)
+			}
+			if !fileStats.IsDir() {
+				return fmt.Errorf("persistent volumes folder %s is a file, but needs to be a directory", aurora.Bold(persistentVolumesBackupFolder))
+			}
 
-				fmt.Printf("1) K8S namespace %s in context %s\n", aurora.Green(k8sContextDefinition.Namespace), aurora.Green(currentContext))
-				fmt.Println("")
-				fmt.Println("   We will connect to the Persistent Volumes via a running Pod.")
-				fmt.Println("")
-				fmt.Println()
-				podName := kubernetes.SelectPod("Please select a Pod whose persistent volumes to restore")
+			currentContext := kubernetes.CurrentContextName()
+			namespace := kubernetes.CurrentNamespace()
+
+			fmt.Printf("1) K8S namespace %s in context %s\n", aurora.Green(namespace), aurora.Green(currentContext))
+			fmt.Println("")
+			fmt.Println("   We will connect to the Persistent Volumes via a running Pod.")
+			fmt.Println("")
+			fmt.Println()
+			podName, err := kubernetes.SelectPod("Please select a Pod whose persistent volumes to restore")
+			if err != nil {
+				return err
+			}
 
-				// query for running pods in current namespace
-				pod, _ := kubernetes.KubernetesClientset().CoreV1().Pods(k8sContextDefinition.Namespace).Get(context.Background(), podName, metav1.GetOptions{})
+			// query for running pods in current namespace
+			pod, _ := kubernetes.KubernetesClientset().CoreV1().Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
 
-				restoreBackupPath = path.Join(restoreBackupPath, time.Now().Format("01-02-2006-15-04-05")+"__"+k8sContextDefinition.Namespace)
-				if err = os.MkdirAll(restoreBackupPath, os.ModePerm); err != nil {
-					fmt.Printf("%s could not create %s:\n    %v\n", aurora.Red("ERROR:"), restoreBackupPath, err)
-				}
+			restoreBackupPath = path.Join(restoreBackupPath, time.Now().Format("01-02-2006-15-04-05")+"__"+namespace)
+			if err = os.MkdirAll(restoreBackupPath, os.ModePerm); err != nil {
+				fmt.Printf("%s could not create %s:\n    %v\n", aurora.Red("ERROR:"), restoreBackupPath, err)
+			}
 
-				// we first iterate over the volumes, as we want to only restore each volume once,
-				// even if it is mounted in multiple containers.
-				for _, volume := range pod.Spec.Volumes {
-					if volume.PersistentVolumeClaim != nil && len(volume.PersistentVolumeClaim.ClaimName) > 0 {
-						// we continue only for persistent volume claims, not for secret volumes (or other volume types)
-
-						container, volumeMount, found := findFirstContainerMountingVolume(pod.Spec.Containers, &volume)
-						if !found {
-							fmt.Println(aurora.Yellow(fmt.Sprintf("WARNING: Did not find mount point for Volume %s\n", volume.Name)))
-							fmt.Println("")
-							fmt.Println("   This means we cannot restore this volume, as it is not mounted in the given container.")
-							fmt.Println("   You can check if another Pod is mounting this volume, then re-run this command and select the other Pod.")
-							fmt.Println("")
-							fmt.Println("Continuing with next volume now.")
-							continue
-						}
+			// we first iterate over the volumes, as we want to only restore each volume once,
+			// even if it is mounted in multiple containers.
+			for _, volume := range pod.Spec.Volumes {
+				if volume.PersistentVolumeClaim != nil && len(volume.PersistentVolumeClaim.ClaimName) > 0 {
+					// we continue only for persistent volume claims, not for secret volumes (or other volume types)
+
+					container, volumeMount, found := findFirstContainerMountingVolume(pod.Spec.Containers, &volume)
+					if !found {
+						fmt.Println(aurora.Yellow(fmt.Sprintf("WARNING: Did not find mount point for Volume %s\n", volume.Name)))
+						fmt.Println("")
+						fmt.Println("   This means we cannot restore this volume, as it is not mounted in the given container.")
+						fmt.Println("   You can check if another Pod is mounting this volume, then re-run this command and select the other Pod.")
+						fmt.Println("")
+						fmt.Println("Continuing with next volume now.")
+						continue
+					}
 
-						persistentVolumesBackupFolders := buildFileListToRead(persistentVolumesBackupFolder, func(fileName string) bool {
-							return true
-						})
+					persistentVolumesBackupFolders := buildFileListToRead(persistentVolumesBackupFolder, func(fileName string) bool {
+						return true
+					})
 
-						prompt := promptui.Select{
-							Label: aurora.Bold(fmt.Sprintf("Which backup should be replayed at %s?", volumeMount.MountPath)),
-							Items: persistentVolumesBackupFolders,
-						}
-						_, chosenPersistentVolumesBackup, err := prompt.Run()
-
-						command, err := wrapexec.RunWrappedCommand(
-							"    [kubectl cp] ",
-							"kubectl",
-							"cp",
-							fmt.Sprintf("%s:%s", podName, volumeMount.MountPath),
-							fmt.Sprintf("%s", filepath.Join(restoreBackupPath, fmt.Sprintf("%s__%s", volume.Name, strings.ReplaceAll(volumeMount.MountPath, "/", "_")))),
-							"-c",
-							container.Name,
+					chosenPersistentVolumesBackup, preselected := chosenBackups[volumeMount.MountPath]
+					if !preselected {
+						i, err := utility.Select(
+							fmt.Sprintf("Which backup should be replayed at %s?", volumeMount.MountPath),
+							persistentVolumesBackupFolders,
+							fmt.Sprintf("pass --backup %s=<folder>", volumeMount.MountPath),
 						)
 						if err != nil {
-							fmt.Printf("%s could not download persistent volume contents:\n    Command: %s\n    Error: %v\n", aurora.Red("ERROR:"), command.String(), err)
-							return 1
+							return fmt.Errorf("no backup selected: %w", err)
 						}
+						chosenPersistentVolumesBackup = persistentVolumesBackupFolders[i]
+					}
 
-						confirmationPrompt := promptui.Prompt{
-							Label:     aurora.Bold("Clear the persistent volume and restore its backup?"),
-							IsConfirm: true,
-						}
-						_, err = confirmationPrompt.Run()
-						if err != nil {
-							fmt.Printf("user aborted.\n")
-							return 1
-						}
+					command, err := wrapexec.RunWrappedCommand(
+						"    [kubectl cp] ",
+						"kubectl",
+						"cp",
+						fmt.Sprintf("%s:%s", podName, volumeMount.MountPath),
+						fmt.Sprintf("%s", filepath.Join(restoreBackupPath, fmt.Sprintf("%s__%s", volume.Name, strings.ReplaceAll(volumeMount.MountPath, "/", "_")))),
+						"-c",
+						container.Name,
+					)
+					if err != nil {
+						return fmt.Errorf("could not download persistent volume contents (command: %s): %w", command.String(), err)
+					}
 
-						// TODO: delete dotfiles "." as well
-						command, err = wrapexec.RunWrappedCommand(
-							"    [kubectl exec] ",
-							"kubectl",
-							"exec",
-							podName,
-							"-c",
-							container.Name,
-							"--",
-							"/bin/sh",
-							"-c",
-							fmt.Sprintf("rm -Rf %s/*", volumeMount.MountPath),
-						)
-						if err != nil {
-							fmt.Printf("%s could not clear persistent volume contents:\n    Command: %s\n    Error: %v\n", aurora.Red("ERROR:"), command.String(), err)
-							return 1
-						}
+					confirmed, err := kubernetes.ConfirmDestructive("Clear the persistent volume and restore its backup?")
+					if err != nil {
+						return err
+					}
+					if !confirmed {
+						return fmt.Errorf("%w by the user", kubernetes.ErrPromptAborted)
+					}
 
-						command, err = wrapexec.RunWrappedCommand(
-							"    [kubectl cp] ",
-							"/bin/bash",
-							"-c",
-							fmt.Sprintf(
-								"tar cf - -C %s . | kubectl exec -i --container=%s %s -- tar xf - -C %s",
-								chosenPersistentVolumesBackup,
-								container.Name,
-								podName,
-								volumeMount.MountPath,
-							),
-						)
-						if err != nil {
-							fmt.Printf("%s could not restore persistent volume contents:\n    Command: %s\n    Error: %v\n", aurora.Red("ERROR:"), command.String(), err)
-							return 1
-						}
+					// TODO: delete dotfiles "." as well
+					command, err = wrapexec.RunWrappedCommand(
+						"    [kubectl exec] ",
+						"kubectl",
+						"exec",
+						podName,
+						"-c",
+						container.Name,
+						"--",
+						"/bin/sh",
+						"-c",
+						fmt.Sprintf("rm -Rf %s/*", volumeMount.MountPath),
+					)
+					if err != nil {
+						return fmt.Errorf("could not clear persistent volume contents (command: %s): %w", command.String(), err)
+					}
 
+					command, err = wrapexec.RunWrappedCommand(
+						"    [kubectl cp] ",
+						"/bin/bash",
+						"-c",
+						fmt.Sprintf(
+							"tar cf - -C %s . | kubectl exec -i --container=%s %s -- tar xf - -C %s",
+							chosenPersistentVolumesBackup,
+							container.Name,
+							podName,
+							volumeMount.MountPath,
+						),
+					)
+					if err != nil {
+						return fmt.Errorf("could not restore persistent volume contents (command: %s): %w", command.String(), err)
 					}
+
 				}
+			}
 
-				return 0
-			})()
-			os.Exit(resultCode)
+			return nil
 		},
 	}
 
 	userHomeDir, _ := os.UserHomeDir()
 	persistentVolumesCommand.Flags().StringVarP(&restoreBackupPath, "restoreBackupPath", "", filepath.Join(userHomeDir, "src/k8s/restore-backups"), "filename that contains the configuration to apply")
+	persistentVolumesCommand.Flags().StringToStringVarP(&chosenBackups, "backup", "", map[string]string{}, "backup folder to replay per mount path (mountPath=folder), instead of being asked; can be given multiple times")
 
 	return persistentVolumesCommand
 }
@@ -4,24 +4,19 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/logrusorgru/aurora"
-	"github.com/manifoldco/promptui"
 	"github.com/sandstorm/sku/pkg/database"
 	"github.com/sandstorm/sku/pkg/kubernetes"
 	"github.com/spf13/cobra"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 )
 
 func BuildPostgresCommand() *cobra.Command {
-	dbHost := ""
-	dbName := ""
-	dbUser := ""
-	dbPassword := ""
+	credentialExpressions := database.CredentialExpressions{}
+	proxyStrategy := ""
 	restoreBackupPath := ""
 
 	mariadbCommand := &cobra.Command{
@@ -33,148 +28,134 @@ func BuildPostgresCommand() *cobra.Command {
 `,
 
 		Args: cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			// we wrap the full code in a closure, and directly execute it,
-			// so that we can run defer statements and they get executed before exiting
-			resultCode := (func() int {
-				//=================================
-				// Preparation (Parameter Parsing)
-				//=================================
-				fmt.Println(aurora.Bold("Restoring Postgres to the Kubernetes cluster"))
-				fmt.Println(aurora.Bold("============================================"))
-				fmt.Println("This is an interactive wizard guiding you through restoring a Postgres database.")
-				fmt.Println("Before any destructive operation, you'll be asked whether you want to continue.")
-				fmt.Println("")
-				fmt.Println("")
-
-				sqlFileName := args[0]
-				if len(sqlFileName) == 0 {
-					fmt.Printf("%s the SQL file must be given as parameter\n", aurora.Red("ERROR:"))
-					return 1
-				}
-
-				fileStats, err := os.Stat(sqlFileName)
-				if err != nil {
-					fmt.Printf("%s SQL File %s not found:\n    %s\n", aurora.Red("ERROR:"), aurora.Bold(sqlFileName), err)
-					return 1
-				}
-				if fileStats.IsDir() {
-					fmt.Printf("%s SQL File %s is a directory, but needs to be a file\n", aurora.Red("ERROR:"), aurora.Bold(sqlFileName))
-					return 1
-				}
-
-				currentContext := kubernetes.KubernetesApiConfig().CurrentContext
-				k8sContextDefinition := kubernetes.KubernetesApiConfig().Contexts[currentContext]
-
-				dbHost = kubernetes.EvalScriptParameter(dbHost)
-				dbName = kubernetes.EvalScriptParameter(dbName)
-				dbUser = kubernetes.EvalScriptParameter(dbUser)
-				dbPassword = kubernetes.EvalScriptParameter(dbPassword)
-
-				localDbProxyPort, db, kubectlPortForward, err := database.PostgresDatabaseConnectionThroughPod(dbHost, dbName, dbUser, dbPassword)
-				if err != nil {
-					fmt.Println(err)
-					return 1
-				}
-				defer kubectlPortForward.Process.Kill()
-				defer db.Close()
-
-				//=================================
-				// Create SQL Backup
-				//=================================
-				fmt.Println("")
-				fmt.Println("")
-				fmt.Printf("4) %s and inport the SQL dump", aurora.Bold("Clear all data"))
-				fmt.Println("")
-				fmt.Println("   After doing an SQL dump, the database will be cleared, and the given data from the backup will be imported.")
-				fmt.Println("")
-
-				restoreBackupPath = path.Join(restoreBackupPath, time.Now().Format("01-02-2006-15-04-05")+"__"+k8sContextDefinition.Namespace)
-				if err = os.MkdirAll(restoreBackupPath, os.ModePerm); err != nil {
-					fmt.Printf("%s could not create %s:\n    %v\n", aurora.Red("ERROR:"), restoreBackupPath, err)
-				}
-
-				pgDump := exec.Command(
-					"pg_dump",
-					"-h", "127.0.0.1",
-					"-p", strconv.Itoa(localDbProxyPort),
-					"-U", dbUser,
-					"--format=plain",
-					"--no-owner",
-					"--no-privileges",
-					"-f", fmt.Sprintf("%s/backup.sql", restoreBackupPath),
-					dbName,
-				)
-				pgDump.Env = append(os.Environ(),
-					fmt.Sprintf("PGPASSWORD=%s", dbPassword),
-				)
-				pgDump.Stdout = os.Stdout
-				pgDump.Stderr = os.Stderr
-
-				fmt.Println("- Starting to execute SQL backup")
-				err = pgDump.Run()
-				if err != nil {
-					fmt.Printf("%s could not run mysqldump:\n    pg_dump %v\n    %v\n", aurora.Red("ERROR:"), strings.Join(pgDump.Args, " "), err)
-					return 1
-				}
-				fmt.Println("- Finished to execute SQL backup")
-
-				//=================================
-				// Empty database
-				//=================================
-				prompt := promptui.Prompt{
-					Label:     aurora.Bold("CLEAR THE DATABASE and IMPORT from backup?"),
-					IsConfirm: true,
-				}
-				_, err = prompt.Run()
-				if err != nil {
-					fmt.Printf("user aborted.\n")
-					return 1
-				}
-
-				err = emptyPostgresDatabase(db, dbName)
-				if err != nil {
-					fmt.Printf("%s could not empty DB:\n    %v\n", aurora.Red("ERROR:"), err)
-					return 1
-				}
-
-				//=================================
-				// Import into database
-				//=================================
-				postgresImport := exec.Command(
-					"bash",
-					"-c",
-					fmt.Sprintf(
-						"psql -h 127.0.0.1 -p %d -U %s %s < %s",
-						localDbProxyPort,
-						dbUser,
-						dbName,
-						sqlFileName,
-					),
-				)
-				postgresImport.Stdout = os.Stdout
-				postgresImport.Stderr = os.Stderr
-				postgresImport.Env = append(os.Environ(),
-					fmt.Sprintf("PGPASSWORD=%s", dbPassword),
-				)
-
-				fmt.Println("- Importing SQL ")
-				err = postgresImport.Run()
-				if err != nil {
-					fmt.Printf("%s could not import DB:\n    Command executed: %s\n    Error: %v\n", aurora.Red("ERROR:"), postgresImport.String(), err)
-					return 1
-				}
-
-				return 0
-			})()
-			os.Exit(resultCode)
+		RunE: func(cmd *cobra.Command, args []string) error {
+			//=================================
+			// Preparation (Parameter Parsing)
+			//=================================
+			fmt.Println(aurora.Bold("Restoring Postgres to the Kubernetes cluster"))
+			fmt.Println(aurora.Bold("============================================"))
+			fmt.Println("This is an interactive wizard guiding you through restoring a Postgres database.")
+			fmt.Println("Before any destructive operation, you'll be asked whether you want to continue.")
+			fmt.Println("")
+			fmt.Println("")
+
+			sqlFileName := args[0]
+			if len(sqlFileName) == 0 {
+				return fmt.Errorf("the SQL file must be given as parameter")
+			}
+
+			fileStats, err := os.Stat(sqlFileName)
+			if err != nil {
+				return fmt.Errorf("SQL File %s not found: %w", aurora.Bold(sqlFileName), err)
+			}
+			if fileStats.IsDir() {
+				return fmt.Errorf("SQL File %s is a directory, but needs to be a file", aurora.Bold(sqlFileName))
+			}
+
+			namespace := kubernetes.CurrentNamespace()
+
+			engine, _ := database.Engine("postgres")
+			parsedProxyStrategy, err := database.ParseProxyStrategy(proxyStrategy)
+			if err != nil {
+				return err
+			}
+			credentials, err := credentialExpressions.Evaluate(kubernetes.NewEvalContext())
+			if err != nil {
+				return err
+			}
+
+			connection, err := database.ConnectThroughPod(engine, credentials, parsedProxyStrategy, 0)
+			if err != nil {
+				return err
+			}
+			defer connection.Close()
+			db, err := connection.OpenSql()
+			if err != nil {
+				return fmt.Errorf("could not open SQL connection: %w", err)
+			}
+			defer db.Close()
+			endpoint := connection.Endpoint()
+
+			//=================================
+			// Create SQL Backup
+			//=================================
+			fmt.Println("")
+			fmt.Println("")
+			fmt.Printf("4) %s and inport the SQL dump", aurora.Bold("Clear all data"))
+			fmt.Println("")
+			fmt.Println("   After doing an SQL dump, the database will be cleared, and the given data from the backup will be imported.")
+			fmt.Println("")
+
+			restoreBackupPath = path.Join(restoreBackupPath, time.Now().Format("01-02-2006-15-04-05")+"__"+namespace)
+			if err = os.MkdirAll(restoreBackupPath, os.ModePerm); err != nil {
+				fmt.Printf("%s could not create %s:\n    %v\n", aurora.Red("ERROR:"), restoreBackupPath, err)
+			}
+
+			pgDump, err := engine.DumpCommand(endpoint, fmt.Sprintf("%s/backup.sql", restoreBackupPath))
+			if err != nil {
+				return fmt.Errorf("could not prepare the SQL backup: %w", err)
+			}
+			pgDump.Stdout = os.Stdout
+			pgDump.Stderr = os.Stderr
+
+			fmt.Println("- Starting to execute SQL backup")
+			err = pgDump.Run()
+			if err != nil {
+				return fmt.Errorf("could not run pg_dump (pg_dump %v): %w", strings.Join(pgDump.Args, " "), err)
+			}
+			fmt.Println("- Finished to execute SQL backup")
+
+			//=================================
+			// Empty database
+			//=================================
+			confirmed, err := kubernetes.ConfirmDestructive("CLEAR THE DATABASE and IMPORT from backup?")
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				return fmt.Errorf("%w by the user", kubernetes.ErrPromptAborted)
+			}
+
+			err = emptyPostgresDatabase(db, credentials.Name)
+			if err != nil {
+				return fmt.Errorf("could not empty DB: %w", err)
+			}
+
+			//=================================
+			// Import into database
+			//=================================
+			sqlFile, err := os.Open(sqlFileName)
+			if err != nil {
+				return fmt.Errorf("could not open SQL file: %w", err)
+			}
+			defer sqlFile.Close()
+
+			postgresImport, err := engine.RestoreCommand(endpoint)
+			if err != nil {
+				return fmt.Errorf("could not prepare the import: %w", err)
+			}
+			postgresImport.Stdin = sqlFile
+			postgresImport.Stdout = os.Stdout
+			postgresImport.Stderr = os.Stderr
+
+			fmt.Println("- Importing SQL ")
+			err = postgresImport.Run()
+			if err != nil {
+				return fmt.Errorf("could not import DB (command executed: %s): %w", postgresImport.String(), err)
+			}
+
+			return nil
 		},
 	}
 
-	mariadbCommand.Flags().StringVarP(&dbHost, "dbHost", "", "eval:configmap('db').DB_HOST", "filename that contains the configuration to apply")
-	mariadbCommand.Flags().StringVarP(&dbName, "dbName", "", "eval:configmap('db').DB_NAME", "filename that contains the configuration to apply")
-	mariadbCommand.Flags().StringVarP(&dbUser, "dbUser", "", "eval:configmap('db').DB_USER", "filename that contains the configuration to apply")
-	mariadbCommand.Flags().StringVarP(&dbPassword, "dbPassword", "", "eval:secret('db').DB_PASSWORD", "filename that contains the configuration to apply")
+	mariadbCommand.Flags().StringVarP(&credentialExpressions.Host, "dbHost", "", "eval:configmap('db').DB_HOST", "filename that contains the configuration to apply")
+	mariadbCommand.Flags().StringVarP(&credentialExpressions.Name, "dbName", "", "eval:configmap('db').DB_NAME", "filename that contains the configuration to apply")
+	mariadbCommand.Flags().StringVarP(&credentialExpressions.User, "dbUser", "", "eval:configmap('db').DB_USER", "filename that contains the configuration to apply")
+	mariadbCommand.Flags().StringVarP(&credentialExpressions.Password, "dbPassword", "", "eval:secret('db').DB_PASSWORD", "filename that contains the configuration to apply")
+	mariadbCommand.Flags().StringVarP(&proxyStrategy, "proxy-strategy", "", string(database.ProxyStrategyDebug), "how to reach the database from within the cluster: debug (debug container in a running pod), pod (dedicated proxy pod) or direct (port-forward to the selected pod)")
+	mariadbCommand.RegisterFlagCompletionFunc("proxy-strategy", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return database.ProxyStrategies, cobra.ShellCompDirectiveNoFileComp
+	})
 	userHomeDir, _ := os.UserHomeDir()
 	mariadbCommand.Flags().StringVarP(&restoreBackupPath, "restoreBackupPath", "", filepath.Join(userHomeDir, "src/k8s/restore-backups"), "filename that contains the configuration to apply")
 
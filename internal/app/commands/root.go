@@ -15,10 +15,21 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	"strings"
 
+	"github.com/logrusorgru/aurora/v3"
+	"github.com/manifoldco/promptui"
+	"github.com/sandstorm/sku/pkg/config"
+	"github.com/sandstorm/sku/pkg/kubernetes"
+	"github.com/sandstorm/sku/pkg/utility"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // RootCmd represents the base command when called without any subcommands
@@ -28,14 +39,214 @@ var RootCmd = &cobra.Command{
 	Long: `
 Sandstorm Kubernetes Tools - Convenience tools to avoid long kubectl calls. Provides
 convenience tooling to switch contexts and namespaces, enter containers, and many more.
+
+To use sku from scripts, pass --non-interactive: then, sku never prompts, but fails with
+exit code 3 if user input would be needed. Selections can be given via --pod, --container,
+--configmap and --workload; confirmations via --yes.
 `,
+	// errors are printed by Execute()
+	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// flags and arguments are valid at this point, so errors from now on are not usage errors.
+		cmd.SilenceUsage = true
+		if err := initKubernetes(); err != nil {
+			return err
+		}
+		if project := config.Project(); project != nil && cmd.Name() != cobra.ShellCompRequestCmd {
+			// stderr, so that the output of commands can still be used in scripts
+			fmt.Fprintf(os.Stderr, "Using %s (context %v, namespace %v)\n", project.Path(), aurora.Green(kubernetes.CurrentContextName()), aurora.Green(kubernetes.CurrentNamespace()))
+		}
+		return applySettingsToFlags(cmd)
+	},
+}
+
+// set via --context and --namespace; they only apply to the current invocation.
+var contextOverride string
+var namespaceOverride string
+
+// initKubernetes loads the sku config and creates the Kubernetes client, respecting --context and --namespace,
+// or else the context and namespace pinned in the project file.
+//
+// NOTE: shell completion (ValidArgsFunction) does not run PersistentPreRunE of the completed command,
+// so completion functions need to call this themselves.
+func initKubernetes() error {
+	if err := config.Init(); err != nil {
+		return err
+	}
+
+	project := config.Project()
+	contextName, namespace := targetContextAndNamespace(contextOverride, namespaceOverride, project)
+
+	err := kubernetes.KubernetesInit(contextName, namespace)
+	if err != nil && project != nil && contextName == project.Context {
+		return fmt.Errorf("%w (pinned in %s)", err, project.Path())
+	}
+	return err
+}
+
+// targetContextAndNamespace applies the context and namespace pinned in the project file (may be nil) where
+// --context and --namespace were not given. The pinned namespace belongs to the pinned context, so it is not
+// used if --context selects another one.
+func targetContextAndNamespace(contextOverride, namespaceOverride string, project *config.ProjectFile) (string, string) {
+	contextName := contextOverride
+	namespace := namespaceOverride
+	if project == nil {
+		return contextName, namespace
+	}
+	if len(contextName) == 0 {
+		contextName = project.Context
+	}
+	if len(namespace) == 0 && (len(contextOverride) == 0 || contextOverride == project.Context) {
+		namespace = project.Namespace
+	}
+	return contextName, namespace
+}
+
+// warnIfProjectDisagrees tells the user that sku context / sku ns do not affect sku commands in this directory,
+// because the project file pins another context or namespace.
+func warnIfProjectDisagrees() {
+	project := config.Project()
+	if project == nil {
+		return
+	}
+	kubeconfig := kubernetes.KubernetesApiConfig()
+	if len(project.Context) > 0 && project.Context != kubeconfig.CurrentContext {
+		fmt.Printf("%v %s pins context %v, but the kubeconfig (and kubectl) uses %v.\n", aurora.Yellow("WARNING:"), project.Path(), aurora.Green(project.Context), aurora.Red(kubeconfig.CurrentContext))
+	}
+	// the namespace of the context sku uses
+	kubeconfigNamespace := ""
+	if context := kubeconfig.Contexts[kubernetes.CurrentContextName()]; context != nil {
+		kubeconfigNamespace = context.Namespace
+	}
+	if len(kubeconfigNamespace) == 0 {
+		kubeconfigNamespace = "default"
+	}
+	if len(project.Namespace) > 0 && project.Namespace != kubeconfigNamespace {
+		fmt.Printf("%v %s pins namespace %v, but the kubeconfig (and kubectl) uses %v in context %v.\n", aurora.Yellow("WARNING:"), project.Path(), aurora.Green(project.Namespace), aurora.Red(kubeconfigNamespace), kubernetes.CurrentContextName())
+	}
+}
+
+// settingsPathAnnotation overrides the command path in the keys of settings for a command; e.g. the database
+// commands use the engine name, so that "sku mysql" and "sku db mysql" share their settings.
+const settingsPathAnnotation = "sku.settingsPath"
+
+// applySettingsToFlags sets all flags of the command which are not given on the command line to the value from
+// the project file or the sku config, if configured. Settings are looked up as "<command path>.<flag>" (e.g. "restore.mariadb.dbHost")
+// and as "<flag>".
+func applySettingsToFlags(cmd *cobra.Command) error {
+	commandPath := strings.Join(strings.Fields(cmd.CommandPath())[1:], ".")
+	if settingsPath, found := cmd.Annotations[settingsPathAnnotation]; found {
+		commandPath = settingsPath
+	}
+	var err error
+	cmd.LocalNonPersistentFlags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Changed || err != nil {
+			return
+		}
+		value, found := config.Lookup(kubernetes.CurrentContextName(), kubernetes.CurrentNamespace(), commandPath+"."+flag.Name, flag.Name)
+		if !found {
+			return
+		}
+		if setErr := cmd.Flags().Set(flag.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %s for --%s in the sku config: %w", value, flag.Name, setErr)
+		}
+		// Changed still means "given on the command line"
+		flag.Changed = false
+	})
+	return err
+}
+
+// setting returns a setting of the project file or the sku config (for the current context and namespace), or defaultValue.
+func setting(key, defaultValue string) string {
+	if value, found := config.Lookup(kubernetes.CurrentContextName(), kubernetes.CurrentNamespace(), key); found {
+		return value
+	}
+	return defaultValue
+}
+
+// kubectlExecutable returns the kubectl configured via the kubectl setting, or else the one found in PATH.
+func kubectlExecutable() (string, error) {
+	if kubectl, found := config.Lookup(kubernetes.CurrentContextName(), kubernetes.CurrentNamespace(), config.KeyKubectl); found {
+		return kubectl, nil
+	}
+	kubectl, err := exec.LookPath("kubectl")
+	if err != nil {
+		return "", fmt.Errorf("kubectl not found in PATH; configure it via: sku config set %s /path/to/kubectl", config.KeyKubectl)
+	}
+	return kubectl, nil
+}
+
+// Exit codes of sku; errors returned by commands are mapped to them in Execute().
+const (
+	ExitCodeError               = 1
+	ExitCodeInteractionRequired = 3
+	ExitCodeContextNotFound     = 4
+	ExitCodeNamespaceNotFound   = 5
+	ExitCodeSecretFetch         = 6
+	ExitCodeProtected           = 7
+	ExitCodePromptAborted       = 130
+)
+
+// ExitCodeFor maps the errors of the library packages to the exit code of sku.
+func ExitCodeFor(err error) int {
+	var remoteExitError kubernetes.RemoteExitError
+	switch {
+	case errors.As(err, &remoteExitError):
+		return remoteExitError.Code
+	case errors.Is(err, utility.ErrInteractionRequired):
+		return ExitCodeInteractionRequired
+	case errors.Is(err, kubernetes.ErrContextNotFound):
+		return ExitCodeContextNotFound
+	case errors.Is(err, kubernetes.ErrNamespaceNotFound):
+		return ExitCodeNamespaceNotFound
+	case errors.Is(err, kubernetes.ErrSecretFetch):
+		return ExitCodeSecretFetch
+	case errors.Is(err, kubernetes.ErrProtected):
+		return ExitCodeProtected
+	case errors.Is(err, kubernetes.ErrPromptAborted), errors.Is(err, promptui.ErrInterrupt), errors.Is(err, promptui.ErrEOF), errors.Is(err, promptui.ErrAbort):
+		return ExitCodePromptAborted
+	default:
+		return ExitCodeError
+	}
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the RootCmd.
 func Execute() {
 	if err := RootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		// the command in the container has printed its errors already
+		if !errors.As(err, &kubernetes.RemoteExitError{}) {
+			fmt.Printf("%s %v\n", aurora.Red("ERROR:"), err)
+		}
+		os.Exit(ExitCodeFor(err))
 	}
 }
+
+func init() {
+	RootCmd.PersistentFlags().StringVarP(&contextOverride, "context", "c", "", "Kubernetes context to use for this command, without switching the current context")
+	RootCmd.PersistentFlags().StringVarP(&namespaceOverride, "namespace", "n", "", "namespace to use for this command, without switching the namespace of the context")
+	RootCmd.RegisterFlagCompletionFunc("context", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if err := kubernetes.KubernetesInit("", ""); err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return kubernetes.ContextNames(), cobra.ShellCompDirectiveNoFileComp
+	})
+	RootCmd.RegisterFlagCompletionFunc("namespace", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if err := initKubernetes(); err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		namespaceList, err := kubernetes.KubernetesClientset().CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return kubernetes.NamespacesToString(namespaceList), cobra.ShellCompDirectiveNoFileComp
+	})
+
+	RootCmd.PersistentFlags().BoolVarP(&utility.NonInteractive, "non-interactive", "", false, "never prompt; fail with exit code 3 if user input would be required")
+	RootCmd.PersistentFlags().BoolVarP(&utility.AssumeYes, "yes", "y", false, "answer all confirmations with yes")
+	RootCmd.PersistentFlags().BoolVarP(&kubernetes.AllowProtected, "allow-protected", "", false, "together with --non-interactive and --yes, allow destructive operations in protected contexts")
+	RootCmd.PersistentFlags().StringVarP(&kubernetes.Preselected.Pod, "pod", "", "", "pod to use instead of being asked; a pod name or a label selector (e.g. app=foo)")
+	RootCmd.PersistentFlags().StringVarP(&kubernetes.Preselected.Container, "container", "", "", "container to use in multi-container pods instead of being asked")
+	RootCmd.PersistentFlags().StringVarP(&kubernetes.Preselected.ConfigMap, "configmap", "", "", "ConfigMap to use for selectInteractively() in eval: expressions instead of being asked")
+	RootCmd.PersistentFlags().StringVarP(&kubernetes.Preselected.Workload, "workload", "", "", "Deployment or StatefulSet (Kind/name or name) to use instead of being asked")
+}
@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/sandstorm/sku/pkg/config"
+)
+
+func TestTargetContextAndNamespace(t *testing.T) {
+	project := &config.ProjectFile{Context: "staging", Namespace: "myns"}
+
+	tests := []struct {
+		name              string
+		contextOverride   string
+		namespaceOverride string
+		project           *config.ProjectFile
+		expectedContext   string
+		expectedNamespace string
+	}{
+		{"no project", "", "", nil, "", ""},
+		{"pinned context and namespace", "", "", project, "staging", "myns"},
+		{"--context of the pinned context", "staging", "", project, "staging", "myns"},
+		{"--context of another context", "prod", "", project, "prod", ""},
+		{"--context and --namespace", "prod", "other", project, "prod", "other"},
+		{"--namespace", "", "other", project, "staging", "other"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contextName, namespace := targetContextAndNamespace(test.contextOverride, test.namespaceOverride, test.project)
+			if contextName != test.expectedContext || namespace != test.expectedNamespace {
+				t.Errorf("expected %s/%s, got %s/%s", test.expectedContext, test.expectedNamespace, contextName, namespace)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	. "github.com/logrusorgru/aurora/v3"
+	"github.com/sandstorm/sku/pkg/config"
 	"github.com/sandstorm/sku/pkg/kubernetes"
 	"github.com/spf13/cobra"
 	"io/ioutil"
@@ -87,8 +88,7 @@ On first run, you'll get an error about untrusted software. '
 			log.Fatalf(Colorize("Repo URL for node %s not found", RedBg).String(), kubernetesNode)
 		}
 
-		userHomeDir, _ := os.UserHomeDir()
-		backupMountDir := userHomeDir + "/src/k8s/backup/" + kubernetesNode
+		backupMountDir := backupMountDirFor(kubernetesNode)
 		os.MkdirAll(backupMountDir, os.ModePerm)
 
 		fmt.Printf("In case you have your yubikey attached, %s\n\n", Colorize("you might need to touch it if it blinks.", BoldFm|BlinkFm))
@@ -100,7 +100,7 @@ On first run, you'll get an error about untrusted software. '
 			panic(err)
 		}
 
-		borgCommand := exec.Command("/usr/local/bin/borg", "mount", "-o", "uid="+sysUser.Uid, "--last", "1", "--strip-components", "1", borgbackupRepoUrl, backupMountDir)
+		borgCommand := exec.Command(setting(config.KeyBorg, "/usr/local/bin/borg"), "mount", "-o", "uid="+sysUser.Uid, "--last", "1", "--strip-components", "1", borgbackupRepoUrl, backupMountDir)
 		env := os.Environ()
 		borgCommand.Env = append(env, fmt.Sprintf(`BORG_RSH=ssh -i %s`, borgbackupSshKeyTempFile.Name()))
 		borgCommand.Stdout = os.Stdout
@@ -134,8 +134,7 @@ ALPHA Quality!
 	Run: func(cmd *cobra.Command, args []string) {
 		kubernetesNode := args[0]
 
-		userHomeDir, _ := os.UserHomeDir()
-		backupMountDir := userHomeDir + "/src/k8s/backup/" + kubernetesNode
+		backupMountDir := backupMountDirFor(kubernetesNode)
 
 		umountCommand := exec.Command("diskutil", "unmount", "force", backupMountDir)
 		umountCommand.Stdout = os.Stdout
@@ -147,3 +146,10 @@ ALPHA Quality!
 		}
 	},
 }
+
+// backupMountDirFor returns the directory the backup of the node is mounted to; configurable via the
+// backupMountPath setting.
+func backupMountDirFor(kubernetesNode string) string {
+	userHomeDir, _ := os.UserHomeDir()
+	return setting(config.KeyBackupMountPath, userHomeDir+"/src/k8s/backup") + "/" + kubernetesNode
+}
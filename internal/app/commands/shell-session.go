@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sandstorm/sku/pkg/kubernetes"
+	"github.com/sandstorm/sku/pkg/utility"
+	"github.com/spf13/cobra"
+)
+
+// the hooks are evaluated by the shell; %[1]s is the (quoted) sku executable, %[2]s the env var for the session.
+var shellSessionHooks = map[string]string{
+	"bash": `# sku shell-session: this shell uses its own copy of the kubeconfig.
+__sku_session_kubeconfig="$(%[1]s shell-session create)" && {
+	export KUBECONFIG="$__sku_session_kubeconfig"
+	export %[2]s="$__sku_session_kubeconfig"
+	__sku_session_cleanup() { rm -f "$%[2]s"; }
+	# chain onto an existing EXIT trap instead of replacing it
+	__sku_session_exit_trap() { printf '%%s' "$3"; }
+	trap "__sku_session_cleanup; $(eval "__sku_session_exit_trap $(trap -p EXIT)")" EXIT
+	unset -f __sku_session_exit_trap
+}
+unset __sku_session_kubeconfig
+`,
+	"zsh": `# sku shell-session: this shell uses its own copy of the kubeconfig.
+__sku_session_kubeconfig="$(%[1]s shell-session create)" && {
+	export KUBECONFIG="$__sku_session_kubeconfig"
+	export %[2]s="$__sku_session_kubeconfig"
+	__sku_session_cleanup() { rm -f "$%[2]s" }
+	zshexit_functions+=(__sku_session_cleanup)
+}
+unset __sku_session_kubeconfig
+`,
+	"fish": `# sku shell-session: this shell uses its own copy of the kubeconfig.
+set -l __sku_session_kubeconfig (%[1]s shell-session create)
+and begin
+	set -gx KUBECONFIG $__sku_session_kubeconfig
+	set -gx %[2]s $__sku_session_kubeconfig
+	function __sku_session_cleanup --on-event fish_exit
+		rm -f $%[2]s
+	end
+end
+`,
+}
+
+func BuildShellSessionCommand() *cobra.Command {
+	shellSessionCommand := &cobra.Command{
+		Use:   "shell-session [bash|zsh|fish]",
+		Short: "Give the current shell its own kubeconfig, so that sku context / sku ns only affect this shell",
+		Long: `
+sku context and sku ns modify ~/.kube/config, so switching the context in one terminal
+switches it for all other terminals as well.
+
+sku shell-session prints a shell hook which copies the kubeconfig to a temporary file
+(readable only by you), and points KUBECONFIG of the current shell to it. Afterwards,
+sku context, sku ns (and kubectl) only affect this shell. The copy is removed when the
+shell exits.
+
+To start a session:
+
+Bash / Zsh:
+
+$ eval "$(sku shell-session bash)"
+$ eval "$(sku shell-session zsh)"
+
+Fish:
+
+$ sku shell-session fish | source
+
+--context and --namespace select the context and namespace the session starts with.
+`,
+		Example: `
+	eval "$(sku --context production shell-session bash)"
+`,
+		DisableFlagsInUseLine: true,
+		ValidArgs:             []string{"bash", "zsh", "fish"},
+		Args:                  cobra.ExactValidArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			skuExecutable, err := utility.GetSkuExecutableFileName()
+			if err != nil {
+				return err
+			}
+			fmt.Printf(shellSessionHooks[args[0]], shellQuote(skuExecutable), kubernetes.SessionKubeconfigEnvVar)
+			return nil
+		},
+	}
+
+	shellSessionCommand.AddCommand(&cobra.Command{
+		Use:   "create",
+		Short: "Create the kubeconfig copy for a shell session, and print its path (used by the shell hook)",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sessionKubeconfig, err := kubernetes.CreateSessionKubeconfig()
+			if err != nil {
+				return err
+			}
+			fmt.Println(sessionKubeconfig)
+			return nil
+		},
+	})
+
+	return shellSessionCommand
+}
+
+// sessionScopeHint is appended to the output of sku context / sku ns, telling where the switch applies.
+func sessionScopeHint() string {
+	if kubernetes.InSession() {
+		return " (only in this shell session)"
+	}
+	return " (in all shells; see sku shell-session)"
+}
+
+// shellQuote quotes s for bash, zsh and fish.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+func init() {
+	RootCmd.AddCommand(BuildShellSessionCommand())
+}
@@ -0,0 +1,263 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/logrusorgru/aurora/v3"
+	"github.com/sandstorm/sku/pkg/database"
+	"github.com/sandstorm/sku/pkg/kubernetes"
+	"github.com/sandstorm/sku/pkg/tunnel"
+	"github.com/spf13/cobra"
+)
+
+func BuildTunnelCommand() *cobra.Command {
+	tunnelCommand := &cobra.Command{
+		Use:   "tunnel",
+		Short: "Keep tunnels to databases and in-cluster hosts open in the background",
+		Long: `
+Opens tunnels like "sku mysql" or "sku forward" do, but in a background daemon (one per context), so that
+no terminal needs to stay open, e.g. for GUI tools. The daemon re-opens broken tunnels, and exits when its
+last tunnel is closed. Its log is in the tunnels directory next to the sku config (~/.config/sku/tunnels).
+`,
+		Example: `
+	sku tunnel up mysql
+	sku tunnel up postgres elasticsearch:9200 9025:mailhog:8025
+	sku tunnel ls
+	sku tunnel down mysql
+	sku tunnel down --all
+`,
+		Args: cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	tunnelCommand.AddCommand(buildTunnelUpCommand())
+	tunnelCommand.AddCommand(buildTunnelDownCommand())
+	tunnelCommand.AddCommand(&cobra.Command{
+		Use:   "ls",
+		Short: "List the tunnels of all contexts",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			statuses, clients, err := tunnel.ListAll()
+			if err != nil {
+				return err
+			}
+			for _, client := range clients {
+				defer client.Close()
+			}
+			if len(statuses) == 0 {
+				fmt.Println("No tunnels are up.")
+				return nil
+			}
+			for _, status := range statuses {
+				printTunnelStatus(status)
+			}
+			return nil
+		},
+	})
+	tunnelCommand.AddCommand(&cobra.Command{
+		Use:    "daemon",
+		Short:  "Run the tunnel daemon of the context (started by sku tunnel up)",
+		Hidden: true,
+		Args:   cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return tunnel.RunDaemon()
+		},
+	})
+
+	return tunnelCommand
+}
+
+func buildTunnelUpCommand() *cobra.Command {
+	proxyStrategy := ""
+	localPort := 0
+
+	tunnelUpCommand := &cobra.Command{
+		Use:   "up [database engine | [localPort:]host:port]...",
+		Short: "Open tunnels to databases (by engine name, with the credentials of sku mysql, ...) or host:port targets",
+		Long: `
+Opens tunnels in the background daemon of the current context. Targets are either a database engine
+(mysql, postgres, mongodb, redis), whose host and port are resolved like for "sku mysql" etc. (including
+remembered credentials and local ports), or host:port like for "sku forward".
+
+Tunnels which are already up (same name in the same namespace) are left alone.
+`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if localPort != 0 && len(args) > 1 {
+				return fmt.Errorf("--%s can only be given for a single target", localPortFlag)
+			}
+			currentContext := kubernetes.CurrentContextName()
+			namespace := kubernetes.CurrentNamespace()
+
+			statuses, clients, err := tunnel.ListAll()
+			if err != nil {
+				return err
+			}
+			for _, client := range clients {
+				defer client.Close()
+			}
+
+			var client *tunnel.Client
+			for _, name := range args {
+				if status, found := findTunnel(statuses, currentContext, namespace, name); found {
+					fmt.Printf("%v is already up:\n", aurora.Green(name))
+					printTunnelStatus(status)
+					continue
+				}
+
+				spec, err := tunnelSpecFor(name, proxyStrategy, localPort)
+				if err != nil {
+					return err
+				}
+				if client == nil {
+					if client, err = tunnel.Connect(currentContext, true); err != nil {
+						return err
+					}
+					defer client.Close()
+				}
+				status, err := client.Up(spec)
+				if err != nil {
+					return fmt.Errorf("could not open tunnel %s (see %s): %w", name, tunnel.LogPath(currentContext), err)
+				}
+				printTunnelStatus(status)
+			}
+			return nil
+		},
+	}
+
+	tunnelUpCommand.Flags().StringVar(&proxyStrategy, "proxy-strategy", "", "how to reach the targets from within the cluster: debug, pod or direct (default: like sku mysql, ... for databases; like sku forward for host:port)")
+	tunnelUpCommand.RegisterFlagCompletionFunc("proxy-strategy", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return database.ProxyStrategies, cobra.ShellCompDirectiveNoFileComp
+	})
+	tunnelUpCommand.Flags().IntVar(&localPort, localPortFlag, 0, "local port of the tunnel (for a single target); by default, the remembered port of a database, or a free port")
+
+	return tunnelUpCommand
+}
+
+// tunnelSpecFor resolves a target of sku tunnel up; asking for the proxy pod or workload if needed.
+func tunnelSpecFor(name, proxyStrategy string, localPort int) (tunnel.Spec, error) {
+	var spec tunnel.Spec
+	engine, engineErr := database.Engine(name)
+
+	if engineErr == nil {
+		// the settings of "sku mysql" etc. apply
+		credentialExpressions := credentialExpressionsFor(engine.Name())
+		for flag, target := range credentialFlags(&credentialExpressions) {
+			*target = setting(engine.Name()+"."+flag, *target)
+		}
+		credentials, err := credentialExpressions.Evaluate(kubernetes.NewEvalContext())
+		if err != nil {
+			return spec, err
+		}
+		spec.TargetHost, spec.TargetPort = credentials.Host, credentials.Port
+		if spec.TargetPort == 0 {
+			spec.TargetPort = engine.DefaultPort()
+		}
+		if len(proxyStrategy) == 0 {
+			proxyStrategy = setting(engine.Name()+".proxy-strategy", string(database.ProxyStrategyDebug))
+		}
+		if localPort, err = databaseLocalPort(engine, credentials.Name, localPort); err != nil {
+			return spec, err
+		}
+	} else {
+		target, err := parseForwardTarget(name)
+		if err != nil {
+			return spec, fmt.Errorf("%s is neither a database engine (%v) nor host:port", name, database.EngineNames())
+		}
+		spec.TargetHost, spec.TargetPort = target.host, target.port
+		if localPort == 0 {
+			localPort = target.localPort
+		}
+		if len(proxyStrategy) == 0 {
+			proxyStrategy = setting("forward.proxy-strategy", string(database.ProxyStrategyPod))
+		}
+	}
+
+	parsedProxyStrategy, err := database.ParseProxyStrategy(proxyStrategy)
+	if err != nil {
+		return spec, err
+	}
+	proxy, err := database.SelectProxy(parsedProxyStrategy)
+	if err != nil {
+		return spec, err
+	}
+
+	spec.Name = name
+	spec.Proxy = proxy.Spec()
+	spec.LocalPort = localPort
+	return spec, nil
+}
+
+func buildTunnelDownCommand() *cobra.Command {
+	all := false
+
+	tunnelDownCommand := &cobra.Command{
+		Use:   "down [name | local port]...",
+		Short: "Close tunnels, given by name (in the current namespace) or local port",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && !all {
+				return fmt.Errorf("pass the tunnels to close, or --all")
+			}
+
+			statuses, clients, err := tunnel.ListAll()
+			if err != nil {
+				return err
+			}
+			for _, client := range clients {
+				defer client.Close()
+			}
+
+			toClose := statuses
+			if !all {
+				toClose = make([]tunnel.Status, 0, len(args))
+				for _, arg := range args {
+					status, found := findTunnel(statuses, kubernetes.CurrentContextName(), kubernetes.CurrentNamespace(), arg)
+					if !found {
+						return fmt.Errorf("no tunnel %s is up in namespace %s (see sku tunnel ls)", arg, kubernetes.CurrentNamespace())
+					}
+					toClose = append(toClose, status)
+				}
+			}
+
+			for _, status := range toClose {
+				if _, err = clients[status.LocalPort].Down(status.LocalPort); err != nil {
+					return err
+				}
+				fmt.Printf("Closed %v (127.0.0.1:%d).\n", aurora.Green(status.Name), status.LocalPort)
+			}
+			return nil
+		},
+	}
+	tunnelDownCommand.Flags().BoolVar(&all, "all", false, "close all tunnels of all contexts")
+
+	return tunnelDownCommand
+}
+
+// findTunnel finds a tunnel by local port, or by name in the given context and namespace.
+func findTunnel(statuses []tunnel.Status, context, namespace, nameOrLocalPort string) (tunnel.Status, bool) {
+	for _, status := range statuses {
+		if strconv.Itoa(status.LocalPort) == nameOrLocalPort ||
+			(status.Name == nameOrLocalPort && status.Context == context && status.Proxy.Namespace == namespace) {
+			return status, true
+		}
+	}
+	return tunnel.Status{}, false
+}
+
+func printTunnelStatus(status tunnel.Status) {
+	via := aurora.Yellow("reconnecting").String()
+	if len(status.Pod) > 0 {
+		via = "via pod " + status.Pod
+	}
+	fmt.Printf("  %-22s -> %-30s %s/%s, %s, up %s (%s)\n",
+		aurora.Green(fmt.Sprintf("127.0.0.1:%d", status.LocalPort)), fmt.Sprintf("%s:%d", status.TargetHost, status.TargetPort),
+		status.Context, status.Proxy.Namespace, aurora.Bold(status.Name), time.Since(status.Started).Round(time.Second), via)
+}
+
+func init() {
+	RootCmd.AddCommand(BuildTunnelCommand())
+}
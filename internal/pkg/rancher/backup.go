@@ -1,6 +1,7 @@
 package rancher
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -10,81 +11,125 @@ import (
 	"regexp"
 )
 
-func RunBackup(apiEndpointUrl string, token string, absoluteStoragePath string) {
+func RunBackup(apiEndpointUrl string, token string, absoluteStoragePath string) error {
 
 	rc := &rancherClient{
 		apiEndpointUrl:      apiEndpointUrl,
 		token:               token,
 		absoluteStoragePath: absoluteStoragePath,
-		httpClient: &http.Client{
-		},
+		httpClient:          &http.Client{},
 	}
 
 	// iterate through the root API - all "links" existing.
-	apiRoot := rc.fetchUrl("")
+	apiRoot, err := rc.fetchUrl("")
+	if err != nil {
+		return err
+	}
 	for k, collectionUrl := range apiRoot.Links {
 		// We do not need self or root
 		// we skip clusterRegistrationTokens as they contain very sensitive information
 		// WORKAROUND: we do not need templates and templateversions (which are huge); because they are simply cached from the remote catalog.
 		// WORKAROUND: LDAP configs are not part of our cluster here.
-		if k == "self" || k == "root" || k == "subscribe"|| k == "clusterRegistrationTokens" || k == "templates" || k == "templateVersions" || k == "ldapConfigs" {
+		if k == "self" || k == "root" || k == "subscribe" || k == "clusterRegistrationTokens" || k == "templates" || k == "templateVersions" || k == "ldapConfigs" {
 			continue
 		}
 
-		fetchCollection(collectionUrl, absoluteStoragePath, rc, func(el collectionElement) {
+		err = fetchCollection(collectionUrl, absoluteStoragePath, rc, func(el collectionElement) error {
 			if k == "clusters" {
 				log.Printf("-- !! extracting StorageClasses")
-				storageClassesUrl := extractLinkTargetUrl(el, "storageClasses")
-				fetchCollection(storageClassesUrl, path.Join(absoluteStoragePath, "_cluster_" + extractId(el, collectionUrl)), rc, nil)
+				storageClassesUrl, err := extractLinkTargetUrl(el, "storageClasses")
+				if err != nil {
+					return err
+				}
+				id, err := extractId(el, collectionUrl)
+				if err != nil {
+					return err
+				}
+				return fetchCollection(storageClassesUrl, path.Join(absoluteStoragePath, "_cluster_"+id), rc, nil)
 			}
 
 			if k == "projects" {
 				log.Printf("-- !! extracting Project details")
 
-				for k, nestedCollectionUrl := range extractLinks(el) {
+				links, err := extractLinks(el)
+				if err != nil {
+					return err
+				}
+				id, err := extractId(el, collectionUrl)
+				if err != nil {
+					return err
+				}
+				for k, nestedCollectionUrl := range links {
 					// we won't dump secrets or namespacedSecrets because of their sensitive nature
 					if k == "self" || k == "remove" || k == "update" || k == "subscribe" || k == "secrets" || k == "namespacedSecrets" {
 						continue
 					}
-						fetchCollection(nestedCollectionUrl, path.Join(absoluteStoragePath, "_project_" + extractId(el, collectionUrl)), rc, nil)
+					err = fetchCollection(nestedCollectionUrl, path.Join(absoluteStoragePath, "_project_"+id), rc, nil)
+					if err != nil {
+						return err
+					}
 				}
 			}
 
+			return nil
 		})
+		if err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-
 type collectionElement map[string]interface{}
 
-func fetchCollection(collectionUrl string, absoluteStoragePath string, rc *rancherClient, elementPostProcessCallback func(element collectionElement)) {
+func fetchCollection(collectionUrl string, absoluteStoragePath string, rc *rancherClient, elementPostProcessCallback func(element collectionElement) error) error {
 	log.Printf("- %s\n", collectionUrl)
-	collection := rc.fetchUrl(collectionUrl)
+	collection, err := rc.fetchUrl(collectionUrl)
+	if err != nil {
+		return err
+	}
 
-	if (collection.Type == "error") {
+	if collection.Type == "error" {
 		log.Printf("- ERROR fetching collection %s; continuing with next one.", collectionUrl)
-		return;
+		return nil
 	}
 
-	ensureValidCollection(collection, collectionUrl)
+	err = ensureValidCollection(collection, collectionUrl)
+	if err != nil {
+		return err
+	}
 
 	// create empty collection storage path
 	collectionStoragePath := path.Join(absoluteStoragePath, collection.ResourceType)
-	ensurePathExistsAndIsEmpty(collectionStoragePath)
+	err = ensurePathExistsAndIsEmpty(collectionStoragePath)
+	if err != nil {
+		return err
+	}
 
 	// dump the individual properties to files.
 	for _, element := range collection.Data {
-		id := extractId(element, collectionUrl)
+		id, err := extractId(element, collectionUrl)
+		if err != nil {
+			return err
+		}
 
-		err := ioutil.WriteFile(path.Join(collectionStoragePath, sanitizeStringForFile(id) + ".json"), prettyPrintJsonString(element), 0644)
+		json, err := prettyPrintJsonString(element)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(path.Join(collectionStoragePath, sanitizeStringForFile(id)+".json"), json, 0644)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
 		if elementPostProcessCallback != nil {
-			elementPostProcessCallback(element)
+			err = elementPostProcessCallback(element)
+			if err != nil {
+				return err
+			}
 		}
 	}
+	return nil
 }
 
 var validFilenamesRegexp, _ = regexp.Compile("[^a-zA-Z0-9-_:.]")
@@ -93,26 +138,25 @@ func sanitizeStringForFile(s string) string {
 	return validFilenamesRegexp.ReplaceAllString(s, "_")
 }
 
-func extractId(element map[string]interface{}, url string) string {
+func extractId(element map[string]interface{}, url string) (string, error) {
 	id, ok := element["id"].(string)
 	if !ok {
-		log.Fatalf("ID could not be extracted for %v", element)
+		return "", fmt.Errorf("ID could not be extracted for %v", element)
 	}
 	if len(id) == 0 {
-		log.Fatalf("ID was null at URL %s", url)
+		return "", fmt.Errorf("ID was null at URL %s", url)
 	}
 
-	return id
+	return id, nil
 }
 
-
-func extractLinks(element collectionElement) map[string]string {
+func extractLinks(element collectionElement) (map[string]string, error) {
 	if element["links"] == nil {
-		log.Fatalf("links key not found in Element %v", element)
+		return nil, fmt.Errorf("links key not found in Element %v", element)
 	}
 	links, ok := element["links"].(map[string]interface{})
 	if !ok {
-		log.Fatalf("links could not be extracted, target type was %s for %v", reflect.TypeOf(element["links"]), element)
+		return nil, fmt.Errorf("links could not be extracted, target type was %s for %v", reflect.TypeOf(element["links"]), element)
 	}
 
 	result := make(map[string]string)
@@ -121,41 +165,42 @@ func extractLinks(element collectionElement) map[string]string {
 		result[k] = v.(string)
 	}
 
-	return result
+	return result, nil
 }
 
-func extractLinkTargetUrl(element collectionElement, linkName string) string {
-	links := extractLinks(element)
+func extractLinkTargetUrl(element collectionElement, linkName string) (string, error) {
+	links, err := extractLinks(element)
+	if err != nil {
+		return "", err
+	}
 	if len(links[linkName]) == 0 {
-		log.Fatalf("link %s could not be extracted in element %v", linkName, links)
+		return "", fmt.Errorf("link %s could not be extracted in element %v", linkName, links)
 	}
 
-	return links[linkName]
+	return links[linkName], nil
 }
 
-func ensurePathExistsAndIsEmpty(path string) {
+func ensurePathExistsAndIsEmpty(path string) error {
 	err := os.RemoveAll(path)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	err = os.MkdirAll(path, 0755)
-	if err != nil {
-		log.Fatal(err)
-	}
+	return os.MkdirAll(path, 0755)
 }
 
-func ensureValidCollection(collection *RancherApiResponse, url string) {
+func ensureValidCollection(collection *RancherApiResponse, url string) error {
 	if collection.Type != "collection" {
-		log.Fatalf("The rancher API URL: %s was not of type collection, found %s", url, collection.Type)
+		return fmt.Errorf("The rancher API URL: %s was not of type collection, found %s", url, collection.Type)
 	}
 
 	if collection.Pagination.Limit == collection.Pagination.Total {
-		log.Fatalf("We would need to paginate for URL %s, which we do not support yet. Needs to be implemented!", url)
+		return fmt.Errorf("We would need to paginate for URL %s, which we do not support yet. Needs to be implemented!", url)
 	}
 
 	if len(collection.ResourceType) == 0 {
-		log.Fatalf("The rancher API URL %s did not have a ResourceType set", url)
+		return fmt.Errorf("The rancher API URL %s did not have a ResourceType set", url)
 	}
 
+	return nil
 }
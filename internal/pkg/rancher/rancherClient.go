@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 )
 
@@ -15,38 +14,43 @@ type rancherClient struct {
 	httpClient          *http.Client
 }
 
-// fetch an URL as given by relativeUrl, and return the body contents as byte array; already pretty printed.
-func (rc *rancherClient) fetchUrl(relativeUrl string) *RancherApiResponse {
-	req, _ := http.NewRequest("GET", joinUrl(rc.apiEndpointUrl, relativeUrl), nil)
+// fetch an URL as given by relativeUrl, and return the parsed response.
+func (rc *rancherClient) fetchUrl(relativeUrl string) (*RancherApiResponse, error) {
+	url, err := joinUrl(rc.apiEndpointUrl, relativeUrl)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", rc.token))
 	resp, err := rc.httpClient.Do(req)
-
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	responseBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	var response *RancherApiResponse
 	err = json.Unmarshal(responseBody, &response)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("could not parse the response of %s: %w", url, err)
 	}
 
-	return response
+	return response, nil
 }
 
-
 type RancherApiResponse struct {
 	Type string `json:"type"`
 
 	// filled for lists of things, where Type == "collection"
-	ResourceType string `json:"resourceType"`
-	Links map[string]string `json:"links"`
+	ResourceType string            `json:"resourceType"`
+	Links        map[string]string `json:"links"`
 
 	// filled for lists of things, where Type == "collection"
 	// is an array of: maps from string to anything
@@ -2,29 +2,23 @@ package rancher
 
 import (
 	"encoding/json"
-	"log"
 	"net/url"
 )
 
-func joinUrl(baseUrl, relativeUrl string) string {
+func joinUrl(baseUrl, relativeUrl string) (string, error) {
 	relativeUrlParsed, err := url.Parse(relativeUrl)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	base, err := url.Parse(baseUrl)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	u := base.ResolveReference(relativeUrlParsed)
 
-	return u.String()
+	return u.String(), nil
 }
 
-func prettyPrintJsonString(element map[string]interface{}) []byte {
-	result, err := json.MarshalIndent(element, "", "  ")
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	return result
+func prettyPrintJsonString(element map[string]interface{}) ([]byte, error) {
+	return json.MarshalIndent(element, "", "  ")
 }
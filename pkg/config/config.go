@@ -0,0 +1,288 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// PathEnvVar overrides the location of the config file.
+const PathEnvVar = "SKU_CONFIG"
+
+// Settings which are not flags of a command.
+const (
+	// KeyKubectl is the kubectl executable used by sku add-config; by default, the one in PATH.
+	KeyKubectl = "kubectl"
+	// KeyBorg is the borg executable used by sku mount-backup.
+	KeyBorg = "borg"
+	// KeyBackupMountPath is where sku mount-backup mounts backups.
+	KeyBackupMountPath = "backupMountPath"
+	// KeyProtected marks a context or namespace as protected (see kubernetes.ConfirmDestructive), if set to "true".
+	KeyProtected = "protected"
+	// KeySelector is the default label selector of sku enter and sku logs, as "enter.selector" and "logs.selector".
+	KeySelector = "selector"
+)
+
+// Settings map setting keys to values. Flags of commands have the key "<command path>.<flag>" (e.g.
+// "restore.mariadb.dbHost"), or just "<flag>" to apply to all commands with this flag.
+type Settings map[string]string
+
+// File is the sku config file (~/.config/sku/config.yaml):
+//
+//	settings:
+//	  kubectl: /opt/homebrew/bin/kubectl
+//	contexts:
+//	  production:
+//	    settings:
+//	      protected: "true"
+//	    namespaces:
+//	      shop:
+//	        settings:
+//	          restore.mariadb.dbHost: "eval:configmap('database').HOST"
+//
+// Namespace settings take precedence over context settings, which take precedence over the global settings.
+type File struct {
+	Settings Settings                 `yaml:"settings,omitempty"`
+	Contexts map[string]*ContextScope `yaml:"contexts,omitempty"`
+
+	// path the file was loaded from
+	path string
+}
+
+type ContextScope struct {
+	Settings   Settings                   `yaml:"settings,omitempty"`
+	Namespaces map[string]*NamespaceScope `yaml:"namespaces,omitempty"`
+}
+
+type NamespaceScope struct {
+	Settings Settings `yaml:"settings,omitempty"`
+}
+
+// Scope selects where a setting is stored.
+type Scope string
+
+const (
+	ScopeGlobal    Scope = "global"
+	ScopeContext   Scope = "context"
+	ScopeNamespace Scope = "namespace"
+)
+
+var Scopes = []string{string(ScopeGlobal), string(ScopeContext), string(ScopeNamespace)}
+
+func ParseScope(scope string) (Scope, error) {
+	for _, s := range Scopes {
+		if s == scope {
+			return Scope(s), nil
+		}
+	}
+	return "", fmt.Errorf("unknown scope %s, use one of %s", scope, strings.Join(Scopes, ", "))
+}
+
+// DefaultPath is $SKU_CONFIG, or ~/.config/sku/config.yaml.
+func DefaultPath() string {
+	if path := os.Getenv(PathEnvVar); len(path) > 0 {
+		return path
+	}
+	userHomeDir, _ := os.UserHomeDir()
+	return filepath.Join(userHomeDir, ".config", "sku", "config.yaml")
+}
+
+// Load reads the config file at path; a missing file results in an empty config.
+func Load(path string) (*File, error) {
+	file := &File{path: path}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read the sku config %s: %w", path, err)
+	}
+	if err = yaml.Unmarshal(content, file); err != nil {
+		return nil, fmt.Errorf("could not parse the sku config %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// Path is the location of the file.
+func (f *File) Path() string {
+	return f.path
+}
+
+// Save writes the file back to its path; only readable by the user, as it may contain credentials.
+func (f *File) Save() error {
+	f.prune()
+	content, err := yaml.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return fmt.Errorf("could not create the directory of %s: %w", f.path, err)
+	}
+	// the mode of WriteFile only applies to new files; files written by older versions were readable by everyone
+	if err = os.Chmod(f.path, 0600); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not restrict the permissions of %s: %w", f.path, err)
+	}
+	return ioutil.WriteFile(f.path, content, 0600)
+}
+
+// prune removes scopes without settings.
+func (f *File) prune() {
+	for contextName, contextScope := range f.Contexts {
+		for namespace, namespaceScope := range contextScope.Namespaces {
+			if len(namespaceScope.Settings) == 0 {
+				delete(contextScope.Namespaces, namespace)
+			}
+		}
+		if len(contextScope.Settings) == 0 && len(contextScope.Namespaces) == 0 {
+			delete(f.Contexts, contextName)
+		}
+	}
+}
+
+// Lookup returns the first of the keys found, searching the namespace, the context and the global settings
+// (in this order). Within a scope, earlier keys take precedence.
+func (f *File) Lookup(contextName, namespace string, keys ...string) (string, bool) {
+	for _, settings := range f.settingsByPrecedence(contextName, namespace) {
+		for _, key := range keys {
+			if value, found := settings[key]; found {
+				return value, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (f *File) settingsByPrecedence(contextName, namespace string) []Settings {
+	result := make([]Settings, 0, 3)
+	if contextScope := f.Contexts[contextName]; contextScope != nil {
+		if namespaceScope := contextScope.Namespaces[namespace]; namespaceScope != nil {
+			result = append(result, namespaceScope.Settings)
+		}
+		result = append(result, contextScope.Settings)
+	}
+	return append(result, f.Settings)
+}
+
+// Set stores a setting in the given scope; an empty value removes the setting.
+func (f *File) Set(scope Scope, contextName, namespace, key, value string) {
+	settings := f.scopeSettings(scope, contextName, namespace)
+	if len(value) == 0 {
+		delete(*settings, key)
+		return
+	}
+	if *settings == nil {
+		*settings = Settings{}
+	}
+	(*settings)[key] = value
+}
+
+// Get returns a setting of exactly the given scope (without falling back to other scopes).
+func (f *File) Get(scope Scope, contextName, namespace, key string) (string, bool) {
+	value, found := (*f.scopeSettings(scope, contextName, namespace))[key]
+	return value, found
+}
+
+func (f *File) scopeSettings(scope Scope, contextName, namespace string) *Settings {
+	if scope == ScopeGlobal {
+		return &f.Settings
+	}
+
+	if f.Contexts == nil {
+		f.Contexts = map[string]*ContextScope{}
+	}
+	if f.Contexts[contextName] == nil {
+		f.Contexts[contextName] = &ContextScope{}
+	}
+	contextScope := f.Contexts[contextName]
+	if scope == ScopeContext {
+		return &contextScope.Settings
+	}
+
+	if contextScope.Namespaces == nil {
+		contextScope.Namespaces = map[string]*NamespaceScope{}
+	}
+	if contextScope.Namespaces[namespace] == nil {
+		contextScope.Namespaces[namespace] = &NamespaceScope{}
+	}
+	return &contextScope.Namespaces[namespace].Settings
+}
+
+// Effective returns all settings applying to the given context and namespace, merged by precedence.
+func (f *File) Effective(contextName, namespace string) Settings {
+	result := Settings{}
+	precedence := f.settingsByPrecedence(contextName, namespace)
+	for i := len(precedence) - 1; i >= 0; i-- {
+		for key, value := range precedence[i] {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// SortedKeys returns the keys of the settings, sorted.
+func (s Settings) SortedKeys() []string {
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var current = &File{path: DefaultPath()}
+var project *ProjectFile
+
+// Init loads the config file from DefaultPath() and the project file of the working directory; afterwards, they
+// are available via Current() and Project().
+func Init() error {
+	file, err := Load(DefaultPath())
+	if err != nil {
+		return err
+	}
+	current = file
+
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	project, err = FindProject(workingDir)
+	return err
+}
+
+// Current returns the config loaded by Init; an empty config before.
+func Current() *File {
+	return current
+}
+
+// Project returns the project file found by Init; nil if there is none.
+func Project() *ProjectFile {
+	return project
+}
+
+// Lookup returns the first of the keys found in the project file, or else in the config (see File.Lookup).
+func Lookup(contextName, namespace string, keys ...string) (string, bool) {
+	if project != nil {
+		for _, key := range keys {
+			if value, found := project.Settings[key]; found {
+				return value, true
+			}
+		}
+	}
+	return current.Lookup(contextName, namespace, keys...)
+}
+
+// Effective returns all settings applying to the given context and namespace, including the project file.
+func Effective(contextName, namespace string) Settings {
+	result := current.Effective(contextName, namespace)
+	if project != nil {
+		for key, value := range project.Settings {
+			result[key] = value
+		}
+	}
+	return result
+}
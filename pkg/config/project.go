@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/logrusorgru/aurora"
+	"gopkg.in/yaml.v2"
+)
+
+// ProjectFileName is the name of the project file; sku looks for it in the working directory and all its parents.
+const ProjectFileName = ".sku.yaml"
+
+// ProjectFile pins the context and namespace for a directory (and its subdirectories), e.g. in an
+// infrastructure repository with one folder per namespace:
+//
+//	context: production
+//	namespace: customer-a
+//	settings:
+//	  enter.selector: app=neos
+//	  dbHost: "eval:configmap('database').HOST"
+//	  backupMountPath: ../backups
+//
+// Its settings take precedence over the sku config; relative paths in backupMountPath are relative to the file.
+// As project files come with cloned repositories, they cannot set executables or unprotect contexts; see
+// projectIgnoredKeys.
+type ProjectFile struct {
+	Context   string   `yaml:"context,omitempty"`
+	Namespace string   `yaml:"namespace,omitempty"`
+	Settings  Settings `yaml:"settings,omitempty"`
+
+	// path the file was loaded from
+	path string
+}
+
+// projectIgnoredKeys are settings which only the sku config can contain: executables sku runs, and the protection
+// of contexts and namespaces (which a project file must not switch off).
+var projectIgnoredKeys = []string{KeyKubectl, KeyBorg, KeyProtected}
+
+// FindProject walks up from dir and loads the first project file found; nil if there is none.
+func FindProject(dir string) (*ProjectFile, error) {
+	for {
+		path := filepath.Join(dir, ProjectFileName)
+		if _, err := os.Stat(path); err == nil {
+			return LoadProject(path)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// LoadProject reads the project file at path.
+func LoadProject(path string) (*ProjectFile, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the project file %s: %w", path, err)
+	}
+	project := &ProjectFile{path: path}
+	if err = yaml.Unmarshal(content, project); err != nil {
+		return nil, fmt.Errorf("could not parse the project file %s: %w", path, err)
+	}
+	for _, key := range projectIgnoredKeys {
+		if _, found := project.Settings[key]; found {
+			fmt.Fprintf(os.Stderr, "%v the setting %s in %s is ignored; it can only be set in the sku config (see sku config).\n", aurora.Yellow("WARNING:"), key, path)
+			delete(project.Settings, key)
+		}
+	}
+	if backupMountPath, found := project.Settings[KeyBackupMountPath]; found && !filepath.IsAbs(backupMountPath) {
+		project.Settings[KeyBackupMountPath] = filepath.Join(filepath.Dir(path), backupMountPath)
+	}
+	return project, nil
+}
+
+// Path is the location of the file.
+func (p *ProjectFile) Path() string {
+	return p.path
+}
@@ -0,0 +1,216 @@
+package database
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/logrusorgru/aurora/v3"
+)
+
+// runInteractive runs a client tool attached to the current terminal, and waits until it exits.
+func runInteractive(command string, args []string, extraEnv ...string) error {
+	cmd := exec.Command(command, args...)
+	if len(extraEnv) > 0 {
+		cmd.Env = append(os.Environ(), extraEnv...)
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+
+	return cmd.Run()
+}
+
+// waitUntilInterrupted keeps the tunnel open (e.g. for GUI tools) until the user presses Ctrl-C.
+func waitUntilInterrupted() {
+	fmt.Println(aurora.Bold("Keep this shell open as long as you want the DB connection to survive."))
+	fmt.Println(aurora.Bold("Press Ctrl-C to close."))
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	<-c
+}
+
+// ShowPasswords prints the passwords GUI tools ask for (--show-password), instead of copying them to the clipboard.
+var ShowPasswords = false
+
+// offerPassword hands the password a GUI tool asks for to the user: via the clipboard, as the terminal (and its
+// scrollback) may be seen by others; it is only printed with ShowPasswords.
+func offerPassword(toolName, password string) {
+	if ShowPasswords {
+		fmt.Println(aurora.Bold(fmt.Sprintf("When %s asks for the password, enter:", toolName)))
+		fmt.Println(aurora.Green(password))
+		return
+	}
+	if err := copyToClipboard(password); err != nil {
+		fmt.Printf("%v could not copy the password to the clipboard (%v); pass --show-password to print it instead.\n", aurora.Yellow("WARNING:"), err)
+		return
+	}
+	fmt.Println(aurora.Bold(fmt.Sprintf("The password is in the clipboard; paste it when %s asks for it.", toolName)))
+}
+
+// copyToClipboard passes text via Stdin (not the command line) to the clipboard tool of the OS.
+func copyToClipboard(text string) error {
+	candidates := [][]string{{"pbcopy"}}
+	if runtime.GOOS == "linux" {
+		candidates = [][]string{{"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}}
+		if len(os.Getenv("WAYLAND_DISPLAY")) > 0 {
+			candidates = append([][]string{{"wl-copy"}}, candidates...)
+		}
+	}
+	names := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		if _, err := exec.LookPath(candidate[0]); err != nil {
+			names = append(names, candidate[0])
+			continue
+		}
+		cmd := exec.Command(candidate[0], candidate[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+	return fmt.Errorf("%s not found", strings.Join(names, ", "))
+}
+
+// beekeeperTool opens Beekeeper Studio, which supports importing the connection from a URL.
+func beekeeperTool(engine DatabaseEngine) ClientTool {
+	return ClientTool{
+		Name:        "beekeeper",
+		Description: "Beekeeper Studio (GUI)",
+		Run: func(endpoint Endpoint, extraArgs []string) error {
+			err := beekeeperApplication.open("", nil)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(aurora.Bold("For Beekeeper Studio, you need to paste the following connection string:"))
+			fmt.Println(aurora.Green(engine.DSN(endpoint)))
+			waitUntilInterrupted()
+			return nil
+		},
+	}
+}
+
+// dbeaverTool opens DBeaver, connecting via its -con command line argument (see
+// https://dbeaver.com/docs/dbeaver/Command-Line/); driver is the DBeaver driver ID, e.g. "postgresql".
+func dbeaverTool(engine DatabaseEngine, driver string) ClientTool {
+	return ClientTool{
+		Name:        "dbeaver",
+		Description: "DBeaver (GUI)",
+		Run: func(endpoint Endpoint, extraArgs []string) error {
+			connection := []string{
+				"driver=" + driver,
+				"host=" + endpoint.Host,
+				fmt.Sprintf("port=%d", endpoint.Port),
+				"database=" + endpoint.Name,
+				"user=" + endpoint.User,
+				// not the password: the command line of DBeaver can be read by all users (ps); DBeaver asks for it
+				"savePassword=false",
+				fmt.Sprintf("name=sku %s %s", engine.Name(), endpoint.Name),
+				"connect=true",
+				"openConsole=true",
+				// the local port may differ next time
+				"save=false",
+			}
+			err := dbeaverApplication.open("", append([]string{"-con", strings.Join(connection, "|")}, extraArgs...))
+			if err != nil {
+				return err
+			}
+			offerPassword("DBeaver", endpoint.Password)
+			waitUntilInterrupted()
+			return nil
+		},
+	}
+}
+
+// datagripTool opens DataGrip with a generated project containing the data source (DataGrip cannot receive
+// connections on the command line); jdbcScheme is e.g. "postgresql", driverRef the DataGrip driver, e.g. "postgresql".
+// The password is not written to disk; see offerPassword.
+func datagripTool(engine DatabaseEngine, jdbcScheme string, driverRef string) ClientTool {
+	return ClientTool{
+		Name:        "datagrip",
+		Description: "DataGrip (GUI)",
+		Run: func(endpoint Endpoint, extraArgs []string) error {
+			// a fresh directory only we can access, not a predictable path in the shared temp directory
+			projectDir, err := ioutil.TempDir("", fmt.Sprintf("sku-datagrip-%s-", engine.Name()))
+			if err != nil {
+				return fmt.Errorf("could not create the DataGrip project: %w", err)
+			}
+			defer os.RemoveAll(projectDir)
+			jdbcUrl := url.URL{
+				Scheme:   "jdbc:" + jdbcScheme,
+				Host:     fmt.Sprintf("%s:%d", endpoint.Host, endpoint.Port),
+				Path:     "/" + endpoint.Name,
+				RawQuery: url.Values{"user": []string{endpoint.User}}.Encode(),
+			}
+			err = writeDatagripProject(projectDir, fmt.Sprintf("sku %s %s", engine.Name(), endpoint.Name), driverRef, jdbcUrl.String())
+			if err != nil {
+				return err
+			}
+
+			err = datagripApplication.open(projectDir, nil)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(aurora.Bold("In DataGrip, open the data source of the project."))
+			offerPassword("DataGrip", endpoint.Password)
+			waitUntilInterrupted()
+			return nil
+		},
+	}
+}
+
+func writeDatagripProject(projectDir, name, driverRef, jdbcUrl string) error {
+	ideaDir := filepath.Join(projectDir, ".idea")
+	if err := os.Mkdir(ideaDir, 0700); err != nil {
+		return fmt.Errorf("could not create the DataGrip project: %w", err)
+	}
+
+	uuid := make([]byte, 16)
+	if _, err := rand.Read(uuid); err != nil {
+		return err
+	}
+	var dataSources bytes.Buffer
+	dataSources.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	dataSources.WriteString("<project version=\"4\">\n")
+	dataSources.WriteString("  <component name=\"DataSourceManagerImpl\" format=\"xml\" multifile-model=\"true\">\n")
+	fmt.Fprintf(&dataSources, "    <data-source source=\"LOCAL\" name=\"%s\" uuid=\"%x-%x-%x-%x-%x\">\n", xmlEscape(name), uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
+	fmt.Fprintf(&dataSources, "      <driver-ref>%s</driver-ref>\n", xmlEscape(driverRef))
+	dataSources.WriteString("      <synchronize>true</synchronize>\n")
+	fmt.Fprintf(&dataSources, "      <jdbc-url>%s</jdbc-url>\n", xmlEscape(jdbcUrl))
+	dataSources.WriteString("    </data-source>\n")
+	dataSources.WriteString("  </component>\n")
+	dataSources.WriteString("</project>\n")
+
+	if err := ioutil.WriteFile(filepath.Join(ideaDir, "dataSources.xml"), dataSources.Bytes(), 0600); err != nil {
+		return fmt.Errorf("could not write the DataGrip project: %w", err)
+	}
+	return nil
+}
+
+func xmlEscape(s string) string {
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(s))
+	return escaped.String()
+}
+
+// usqlTool opens the universal SQL CLI https://github.com/xo/usql
+func usqlTool(engine DatabaseEngine) ClientTool {
+	return ClientTool{
+		Name:        "usql",
+		Description: "usql universal command-line interface",
+		Run: func(endpoint Endpoint, extraArgs []string) error {
+			return runInteractive("usql", append([]string{engine.DSN(endpoint)}, extraArgs...))
+		},
+	}
+}
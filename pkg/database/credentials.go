@@ -0,0 +1,55 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/sandstorm/sku/pkg/kubernetes"
+)
+
+// CredentialExpressions are the database credentials as given on the command line. Each of them
+// can be a literal value or an "eval:" expression (see kubernetes.EvalContext).
+type CredentialExpressions struct {
+	Host     string
+	Port     string
+	Name     string
+	User     string
+	Password string
+}
+
+// Evaluate resolves all expressions in the given context. An empty Port (or one evaluating to an empty value, like
+// the port of a URL without one) means the default port of the engine.
+func (e CredentialExpressions) Evaluate(evalContext *kubernetes.EvalContext) (Credentials, error) {
+	credentials := Credentials{}
+	fields := []struct {
+		expression string
+		target     *string
+	}{
+		{e.Host, &credentials.Host},
+		{e.Name, &credentials.Name},
+		{e.User, &credentials.User},
+		{e.Password, &credentials.Password},
+	}
+	for _, field := range fields {
+		value, err := evalContext.Eval(field.expression)
+		if err != nil {
+			return Credentials{}, err
+		}
+		*field.target = value
+	}
+
+	if len(e.Port) > 0 {
+		port, err := evalContext.Eval(e.Port)
+		if err != nil {
+			return Credentials{}, err
+		}
+		if len(port) > 0 {
+			credentials.Port, err = strconv.Atoi(port)
+			if err != nil {
+				return Credentials{}, fmt.Errorf("database port %s is not a number: %w", port, err)
+			}
+		}
+	}
+
+	return credentials, nil
+}
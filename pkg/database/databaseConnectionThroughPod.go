@@ -3,114 +3,108 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	_ "github.com/go-sql-driver/mysql"
-	_ "github.com/jackc/pgx/v4/stdlib"
 	"github.com/logrusorgru/aurora/v3"
-	"github.com/phayes/freeport"
 	"github.com/sandstorm/sku/pkg/kubernetes"
-	"os"
-	"os/exec"
 	"strconv"
-	"strings"
 	"time"
 )
 
-func MysqlDatabaseConnectionThroughPod(dbHost, dbName, dbUser, dbPassword string) (int, *sql.DB, *exec.Cmd, error) {
-	return databaseConnectionThroughPod(dbHost, dbName, dbUser, dbPassword, 3306, func(localDbProxyPort int) (*sql.DB, error) {
-		return sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(127.0.0.1:%d)/%s", dbUser, dbPassword, localDbProxyPort, dbName))
-	})
+const databaseAvailabilityTimeout = 2 * time.Minute
+
+// Connection is a database inside the cluster, reachable from the local machine through a Forward, which is
+// re-opened on the same local port if the proxy pod dies.
+type Connection struct {
+	Engine      DatabaseEngine
+	Credentials Credentials
+	Forward     *Forward
+}
+
+// Endpoint returns where (and how) the database can be reached from the local machine.
+func (c *Connection) Endpoint() Endpoint {
+	endpoint := Endpoint(c.Credentials)
+	endpoint.Host = "127.0.0.1"
+	endpoint.Port = c.Forward.LocalPort
+	return endpoint
+}
+
+// OpenSql opens a database/sql connection; only supported for SQL engines.
+func (c *Connection) OpenSql() (*sql.DB, error) {
+	sqlEngine, ok := c.Engine.(SqlDatabaseEngine)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", c.Engine.Name(), ErrNotSupported)
+	}
+	return sqlEngine.OpenSql(c.Endpoint())
 }
 
-func PostgresDatabaseConnectionThroughPod(dbHost, dbName, dbUser, dbPassword string) (int, *sql.DB, *exec.Cmd, error) {
-	// see https://github.com/jackc/pgx/blob/master/stdlib/sql.go
-	return databaseConnectionThroughPod(dbHost, dbName, dbUser, dbPassword, 5432, func(localDbProxyPort int) (*sql.DB, error) {
-		return sql.Open("pgx", fmt.Sprintf("postgres://%s:%s@127.0.0.1:%d/%s", dbUser, dbPassword, localDbProxyPort, dbName))
-	})
+func (c *Connection) Close() error {
+	return c.Forward.Close()
 }
 
-func databaseConnectionThroughPod(dbHost, dbName, dbUser, dbPassword string, dbPort int, sqlConnectionFactory func(localDbProxyPort int) (*sql.DB, error)) (int, *sql.DB, *exec.Cmd, error) {
-	currentContext := kubernetes.KubernetesApiConfig().CurrentContext
-	k8sContextDefinition := kubernetes.KubernetesApiConfig().Contexts[currentContext]
+// ConnectThroughPod opens a tunnel to the database (using the given proxy strategy) from localPort (a free port
+// if 0), and waits until the database responds to pings. If credentials.Port is 0, the default port of the
+// engine is used.
+func ConnectThroughPod(engine DatabaseEngine, credentials Credentials, proxyStrategy ProxyStrategy, localPort int) (*Connection, error) {
+	if credentials.Port == 0 {
+		credentials.Port = engine.DefaultPort()
+	}
+
+	currentContext := kubernetes.CurrentContextName()
+	namespace := kubernetes.CurrentNamespace()
 
-	fmt.Printf("1) K8S namespace %s in context %s\n", aurora.Green(k8sContextDefinition.Namespace), aurora.Green(currentContext))
+	fmt.Printf("1) K8S namespace %s in context %s\n", aurora.Green(namespace), aurora.Green(currentContext))
 	fmt.Println("")
-	fmt.Println("   We will connect to the database by adding a Debug Container to an already-running Pod")
-	fmt.Println("   in the namespace, so that we won't have problems with Network Policies etc.")
+	fmt.Printf("   %s\n", describeProxyStrategy(proxyStrategy))
 	fmt.Println("")
 	fmt.Println()
-	proxyPodName := kubernetes.SelectPod("Please select a Pod to use as a proxy for connecting to the Database")
 
 	fmt.Println("2) Database connection parameters")
 	fmt.Println("")
 
-	fmt.Printf("   We will use the following database credentials to connect from within the Pod %s:\n", aurora.Green(proxyPodName))
+	fmt.Println("   We will use the following database credentials to connect from within the cluster:")
 	fmt.Println("")
 	fmt.Println("")
-	fmt.Printf("  - DB Host: %s\n", aurora.Green(dbHost))
-	fmt.Printf("  - DB Name: %s\n", aurora.Green(dbName))
-	fmt.Printf("  - DB User: %s\n", aurora.Green(dbUser))
-	fmt.Printf("  - DB Password length: %s\n", aurora.Green(strconv.Itoa(len(dbPassword))))
+	fmt.Printf("  - DB Engine: %s\n", aurora.Green(engine.Name()))
+	fmt.Printf("  - DB Host: %s\n", aurora.Green(fmt.Sprintf("%s:%d", credentials.Host, credentials.Port)))
+	fmt.Printf("  - DB Name: %s\n", aurora.Green(credentials.Name))
+	fmt.Printf("  - DB User: %s\n", aurora.Green(credentials.User))
+	fmt.Printf("  - DB Password length: %s\n", aurora.Green(strconv.Itoa(len(credentials.Password))))
 
 	//=================================
 	// Connect to DB via Proxy Pod
 	//=================================
 	fmt.Println("3) Trying to connect...")
 	fmt.Println("")
-	kubectlDebug := exec.Command(
-		"kubectl",
-		"debug",
-		proxyPodName,
-		"--image=alpine/socat",
-		"--image-pull-policy=Always",
-		"--arguments-only=true",
-		"--",
-		// here follow the socat arguments
-		fmt.Sprintf("tcp-listen:%d,fork,reuseaddr", dbPort),
-		fmt.Sprintf("tcp-connect:%s:%d", dbHost, dbPort),
-	)
-	err := kubectlDebug.Run()
+	proxy, err := SelectProxy(proxyStrategy)
 	if err != nil {
-		return 0, nil, nil, fmt.Errorf("%s could not run kubectl debug:\n    kubectl debug %v\n    %v\n", aurora.Red("ERROR:"), strings.Join(kubectlDebug.Args, " "), err)
+		return nil, err
 	}
-	fmt.Println("  - Started kubectl debug")
-
-	localDbProxyPort, err := freeport.GetFreePort()
+	forward, err := StartForward(proxy, localPort, credentials.Host, credentials.Port)
 	if err != nil {
-		return 0, nil, nil, fmt.Errorf("%s did not find a free port:\n    %v\n", aurora.Red("ERROR:"), err)
+		return nil, err
 	}
+	fmt.Printf("  - Started port-forward from 127.0.0.1:%d\n", forward.LocalPort)
 
-	kubectlPortForward := exec.Command(
-		"kubectl",
-		"port-forward",
-		fmt.Sprintf("pod/%s", proxyPodName),
-		fmt.Sprintf("%d:%d", localDbProxyPort, dbPort),
-	)
-	kubectlPortForward.Stdout = os.Stdout
-	kubectlPortForward.Stderr = os.Stderr
-
-	err = kubectlPortForward.Start()
-	if err != nil {
-		return 0, nil, nil, fmt.Errorf("%s could not run kubectl port-forward:\n    kubectl port-forward %v\n    %v\n", aurora.Red("ERROR:"), strings.Join(kubectlPortForward.Args, " "), err)
+	connection := &Connection{
+		Engine:      engine,
+		Credentials: credentials,
+		Forward:     forward,
 	}
-	fmt.Println("  - Started kubectl port-forward")
 
-	db, err := sqlConnectionFactory(localDbProxyPort)
-	if err != nil {
-		kubectlPortForward.Process.Kill()
-		return 0, nil, nil, fmt.Errorf("%s connection could not be created:\n    %v\n", aurora.Red("ERROR:"), err)
-	}
-
-	waitTime, _ := time.ParseDuration("1s")
+	deadline := time.Now().Add(databaseAvailabilityTimeout)
 	for {
-		err = db.Ping()
+		err = engine.Ping(connection.Endpoint())
 		if err == nil {
 			break
 		}
+		if time.Now().After(deadline) {
+			connection.Close()
+			return nil, fmt.Errorf("database was not available after %s: %w", databaseAvailabilityTimeout, err)
+		}
 
-		time.Sleep(waitTime)
+		time.Sleep(1 * time.Second)
 		fmt.Println("- Waiting for Database to be available")
 	}
-	fmt.Println("- Got SQL connection")
+	fmt.Println("- Got database connection")
 
-	return localDbProxyPort, db, kubectlPortForward, nil
+	return connection, nil
 }
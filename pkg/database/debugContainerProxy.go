@@ -0,0 +1,326 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sandstorm/sku/pkg/kubernetes"
+	clientV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	typedCoreV1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// ProxyContainerPrefix is the name prefix of all socat debug containers sku adds to pods.
+const ProxyContainerPrefix = "sku-socat-"
+
+// proxyTargetEnvVar is set on each proxy container, so that we can find out later where it connects to.
+const proxyTargetEnvVar = "SKU_PROXY_TARGET"
+
+const proxyImage = "alpine/socat"
+
+const proxyStartupTimeout = 2 * time.Minute
+
+// ProxyContainer describes a socat debug container which sku has added to a pod.
+type ProxyContainer struct {
+	Namespace     string
+	PodName       string
+	ContainerName string
+	Target        string
+	State         string
+	Running       bool
+}
+
+// proxyContainerName is deterministic for a given target, so that later invocations can re-use a running proxy.
+// As ephemeral containers can neither be restarted nor removed from a pod, a terminated proxy is replaced by
+// a new container with an increasing generation suffix.
+func proxyContainerName(targetHost string, targetPort int, generation int) string {
+	name := fmt.Sprintf("%s%08x", ProxyContainerPrefix, proxyTargetHash(targetHost, targetPort))
+	if generation > 0 {
+		name = fmt.Sprintf("%s-%d", name, generation)
+	}
+	return name
+}
+
+// proxyListenPort is the preferred port socat listens on. It is derived from the target, so that proxies for
+// different targets usually get different ports; see freeProxyListenPort for proxies sharing a pod.
+func proxyListenPort(targetHost string, targetPort int) int {
+	return 20000 + int(proxyTargetHash(targetHost, targetPort)%10000)
+}
+
+// freeProxyListenPort starts at proxyListenPort, and skips all ports declared by the pod's containers or used by
+// other proxy containers in it. Ports a process opens without declaring them cannot be detected.
+func freeProxyListenPort(pod *clientV1.Pod, targetHost string, targetPort int) int {
+	usedPorts := make(map[int]bool)
+	for _, containers := range [][]clientV1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			for _, port := range container.Ports {
+				usedPorts[int(port.ContainerPort)] = true
+			}
+		}
+	}
+	for _, container := range pod.Spec.EphemeralContainers {
+		for _, port := range container.Ports {
+			usedPorts[int(port.ContainerPort)] = true
+		}
+		if listenPort, found := proxyContainerListenPort(container); found {
+			usedPorts[listenPort] = true
+		}
+	}
+
+	listenPort := proxyListenPort(targetHost, targetPort)
+	for usedPorts[listenPort] {
+		listenPort = 20000 + (listenPort-20000+1)%10000
+	}
+	return listenPort
+}
+
+// proxyContainerListenPort reads the port a proxy container listens on from its socat arguments.
+func proxyContainerListenPort(container clientV1.EphemeralContainer) (int, bool) {
+	for _, arg := range container.Args {
+		if !strings.HasPrefix(arg, "tcp-listen:") {
+			continue
+		}
+		address := strings.SplitN(strings.TrimPrefix(arg, "tcp-listen:"), ",", 2)[0]
+		if listenPort, err := strconv.Atoi(address); err == nil {
+			return listenPort, true
+		}
+	}
+	return 0, false
+}
+
+func ephemeralContainer(pod *clientV1.Pod, containerName string) *clientV1.EphemeralContainer {
+	for i, container := range pod.Spec.EphemeralContainers {
+		if container.Name == containerName {
+			return &pod.Spec.EphemeralContainers[i]
+		}
+	}
+	return nil
+}
+
+func proxyTargetHash(targetHost string, targetPort int) uint32 {
+	hash := fnv.New32a()
+	hash.Write([]byte(fmt.Sprintf("%s:%d", targetHost, targetPort)))
+	return hash.Sum32()
+}
+
+// EnsureDebugContainerProxy makes sure a socat debug container forwarding to targetHost:targetPort is running
+// in the given pod, and returns the port it listens on inside the pod. A running proxy container for the same
+// target is re-used.
+func EnsureDebugContainerProxy(namespace, podName, targetHost string, targetPort int) (int, error) {
+	pod, err := kubernetes.KubernetesClientset().CoreV1().Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("could not fetch pod %s: %w", podName, err)
+	}
+
+	for generation := 0; ; generation++ {
+		containerName := proxyContainerName(targetHost, targetPort, generation)
+		container := ephemeralContainer(pod, containerName)
+		if container == nil {
+			listenPort := freeProxyListenPort(pod, targetHost, targetPort)
+			fmt.Printf("  - Adding debug container %s to pod %s\n", containerName, podName)
+			err = injectEphemeralContainer(kubernetes.KubernetesClientset().CoreV1().Pods(namespace), podName, buildProxyContainer(containerName, listenPort, targetHost, targetPort))
+			if err != nil {
+				return 0, err
+			}
+			return listenPort, waitForEphemeralContainerRunning(namespace, podName, containerName)
+		}
+
+		status := ephemeralContainerStatus(pod, containerName)
+		if status != nil && status.State.Terminated != nil {
+			// this proxy is gone for good; try the next generation.
+			continue
+		}
+
+		listenPort, found := proxyContainerListenPort(*container)
+		if !found {
+			return 0, fmt.Errorf("could not find the port of debug container %s in pod %s", containerName, podName)
+		}
+		if status != nil && status.State.Running != nil {
+			fmt.Printf("  - Re-using running debug container %s in pod %s\n", containerName, podName)
+			return listenPort, nil
+		}
+
+		// the container is still starting up
+		return listenPort, waitForEphemeralContainerRunning(namespace, podName, containerName)
+	}
+}
+
+func buildProxyContainer(containerName string, listenPort int, targetHost string, targetPort int) clientV1.EphemeralContainer {
+	return clientV1.EphemeralContainer{
+		EphemeralContainerCommon: clientV1.EphemeralContainerCommon{
+			Name:            containerName,
+			Image:           proxyImage,
+			ImagePullPolicy: clientV1.PullAlways,
+			Args: []string{
+				fmt.Sprintf("tcp-listen:%d,fork,reuseaddr", listenPort),
+				fmt.Sprintf("tcp-connect:%s:%d", targetHost, targetPort),
+			},
+			Env: []clientV1.EnvVar{
+				{Name: proxyTargetEnvVar, Value: fmt.Sprintf("%s:%d", targetHost, targetPort)},
+			},
+			TerminationMessagePolicy: clientV1.TerminationMessageReadFile,
+		},
+	}
+}
+
+// injectEphemeralContainer adds the container via the pods/ephemeralcontainers subresource.
+//
+// Since Kubernetes 1.22, the subresource works on the Pod itself; before, it expected an EphemeralContainers
+// object. We try the current API first, and fall back to the old one only if the server rejects the Pod body
+// (like kubectl debug does); all other errors, e.g. missing permissions, are returned as they are.
+func injectEphemeralContainer(pods typedCoreV1.PodInterface, podName string, container clientV1.EphemeralContainer) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"ephemeralContainers": []clientV1.EphemeralContainer{container},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	pod, err := pods.Patch(context.Background(), podName, types.StrategicMergePatchType, patch, metav1.PatchOptions{}, "ephemeralcontainers")
+	if err == nil && hasEphemeralContainer(pod, container.Name) {
+		return nil
+	}
+	if statusError, ok := err.(*errors.StatusError); ok && statusError.Status().Reason == metav1.StatusReasonNotFound && (statusError.Status().Details == nil || statusError.Status().Details.Name == "") {
+		// a missing subresource (in contrast to a missing pod) means the feature is switched off.
+		return fmt.Errorf("ephemeral containers are disabled for this cluster: %w", err)
+	}
+	// older API servers do not know the Pod kind for this subresource
+	if err != nil && !runtime.IsNotRegisteredError(err) && !errors.IsBadRequest(err) {
+		return fmt.Errorf("could not add debug container to pod %s: %w", podName, err)
+	}
+
+	ephemeralContainers, legacyErr := pods.GetEphemeralContainers(context.Background(), podName, metav1.GetOptions{})
+	if legacyErr != nil {
+		if err != nil {
+			return fmt.Errorf("could not add debug container to pod %s: %w", podName, err)
+		}
+		return fmt.Errorf("could not add debug container to pod %s: %w", podName, legacyErr)
+	}
+	ephemeralContainers.EphemeralContainers = append(ephemeralContainers.EphemeralContainers, container)
+	_, legacyErr = pods.UpdateEphemeralContainers(context.Background(), podName, ephemeralContainers, metav1.UpdateOptions{})
+	if legacyErr != nil {
+		return fmt.Errorf("could not add debug container to pod %s: %w", podName, legacyErr)
+	}
+	return nil
+}
+
+func waitForEphemeralContainerRunning(namespace, podName, containerName string) error {
+	fmt.Printf("  - Waiting for debug container %s to be running\n", containerName)
+	deadline := time.Now().Add(proxyStartupTimeout)
+	for time.Now().Before(deadline) {
+		pod, err := kubernetes.KubernetesClientset().CoreV1().Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("could not fetch pod %s: %w", podName, err)
+		}
+
+		status := ephemeralContainerStatus(pod, containerName)
+		switch {
+		case status == nil:
+			// not yet scheduled by the kubelet
+		case status.State.Running != nil:
+			return nil
+		case status.State.Terminated != nil:
+			return fmt.Errorf("debug container %s terminated: %s %s", containerName, status.State.Terminated.Reason, status.State.Terminated.Message)
+		case status.State.Waiting != nil && isFatalWaitingReason(status.State.Waiting.Reason):
+			return fmt.Errorf("debug container %s cannot start: %s %s", containerName, status.State.Waiting.Reason, status.State.Waiting.Message)
+		}
+
+		time.Sleep(1 * time.Second)
+	}
+
+	return fmt.Errorf("debug container %s was not running after %s", containerName, proxyStartupTimeout)
+}
+
+func isFatalWaitingReason(reason string) bool {
+	switch reason {
+	case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "CreateContainerConfigError", "CreateContainerError":
+		return true
+	}
+	return false
+}
+
+func hasEphemeralContainer(pod *clientV1.Pod, containerName string) bool {
+	for _, container := range pod.Spec.EphemeralContainers {
+		if container.Name == containerName {
+			return true
+		}
+	}
+	return false
+}
+
+func ephemeralContainerStatus(pod *clientV1.Pod, containerName string) *clientV1.ContainerStatus {
+	for i, status := range pod.Status.EphemeralContainerStatuses {
+		if status.Name == containerName {
+			return &pod.Status.EphemeralContainerStatuses[i]
+		}
+	}
+	return nil
+}
+
+// hasRunningProxyContainer returns true if a proxy debug container for the target is running in the pod.
+func hasRunningProxyContainer(pod *clientV1.Pod, targetHost string, targetPort int) bool {
+	for generation := 0; ; generation++ {
+		containerName := proxyContainerName(targetHost, targetPort, generation)
+		if !hasEphemeralContainer(pod, containerName) {
+			return false
+		}
+		if status := ephemeralContainerStatus(pod, containerName); status != nil && status.State.Running != nil {
+			return true
+		}
+	}
+}
+
+// ListProxyContainers returns all socat debug containers sku has added to pods of the given namespace.
+// An empty namespace lists the proxy containers of all namespaces.
+func ListProxyContainers(namespace string) ([]ProxyContainer, error) {
+	podList, err := kubernetes.KubernetesClientset().CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not list pods: %w", err)
+	}
+
+	proxyContainers := make([]ProxyContainer, 0)
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		for _, container := range pod.Spec.EphemeralContainers {
+			if !strings.HasPrefix(container.Name, ProxyContainerPrefix) {
+				continue
+			}
+
+			proxyContainer := ProxyContainer{
+				Namespace:     pod.Namespace,
+				PodName:       pod.Name,
+				ContainerName: container.Name,
+				State:         "Pending",
+			}
+			for _, env := range container.Env {
+				if env.Name == proxyTargetEnvVar {
+					proxyContainer.Target = env.Value
+				}
+			}
+			if status := ephemeralContainerStatus(pod, container.Name); status != nil {
+				switch {
+				case status.State.Running != nil:
+					proxyContainer.State = "Running"
+					proxyContainer.Running = true
+				case status.State.Terminated != nil:
+					proxyContainer.State = "Terminated: " + status.State.Terminated.Reason
+				case status.State.Waiting != nil:
+					proxyContainer.State = "Waiting: " + status.State.Waiting.Reason
+				}
+			}
+			proxyContainers = append(proxyContainers, proxyContainer)
+		}
+	}
+
+	return proxyContainers, nil
+}
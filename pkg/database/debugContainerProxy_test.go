@@ -0,0 +1,65 @@
+package database
+
+import (
+	"testing"
+
+	clientV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clientTesting "k8s.io/client-go/testing"
+)
+
+// fakeEphemeralContainersClientset rejects patches of pods/ephemeralcontainers with patchErr, and records the
+// containers written via the legacy EphemeralContainers API.
+func fakeEphemeralContainersClientset(patchErr error, legacyContainers *[]clientV1.EphemeralContainer) *fake.Clientset {
+	clientset := fake.NewSimpleClientset(&clientV1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns"}})
+	clientset.PrependReactor("patch", "pods", func(action clientTesting.Action) (bool, runtime.Object, error) {
+		return action.GetSubresource() == "ephemeralcontainers", nil, patchErr
+	})
+	clientset.PrependReactor("get", "pods", func(action clientTesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "ephemeralcontainers" {
+			return false, nil, nil
+		}
+		return true, &clientV1.EphemeralContainers{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns"}}, nil
+	})
+	clientset.PrependReactor("update", "pods", func(action clientTesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "ephemeralcontainers" {
+			return false, nil, nil
+		}
+		ephemeralContainers := action.(clientTesting.UpdateAction).GetObject().(*clientV1.EphemeralContainers)
+		*legacyContainers = ephemeralContainers.EphemeralContainers
+		return true, ephemeralContainers, nil
+	})
+	return clientset
+}
+
+func TestInjectEphemeralContainerFallsBackToLegacyApiIfPodKindIsNotRegistered(t *testing.T) {
+	// what a Kubernetes < 1.22 API server responds to a Pod body on pods/ephemeralcontainers
+	notRegistered := errors.NewBadRequest(`no kind "Pod" is registered for version "v1" in scheme "k8s.io/kubernetes/pkg/api/legacyscheme/scheme.go:30"`)
+	var legacyContainers []clientV1.EphemeralContainer
+	clientset := fakeEphemeralContainersClientset(notRegistered, &legacyContainers)
+
+	container := buildProxyContainer("sku-socat-test", 20000, "db", 3306)
+	if err := injectEphemeralContainer(clientset.CoreV1().Pods("ns"), "app", container); err != nil {
+		t.Fatalf("expected the legacy API to be used, got %v", err)
+	}
+	if len(legacyContainers) != 1 || legacyContainers[0].Name != container.Name {
+		t.Fatalf("expected the container to be added via the legacy API, got %v", legacyContainers)
+	}
+}
+
+func TestInjectEphemeralContainerReturnsOtherErrors(t *testing.T) {
+	forbidden := errors.NewForbidden(clientV1.Resource("pods/ephemeralcontainers"), "app", nil)
+	var legacyContainers []clientV1.EphemeralContainer
+	clientset := fakeEphemeralContainersClientset(forbidden, &legacyContainers)
+
+	err := injectEphemeralContainer(clientset.CoreV1().Pods("ns"), "app", buildProxyContainer("sku-socat-test", 20000, "db", 3306))
+	if !errors.IsForbidden(err) {
+		t.Fatalf("expected the forbidden error, got %v", err)
+	}
+	if legacyContainers != nil {
+		t.Fatalf("expected the legacy API not to be used, got %v", legacyContainers)
+	}
+}
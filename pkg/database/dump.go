@@ -0,0 +1,74 @@
+package database
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// DumpFormat is an output format of a dump, e.g. plain SQL.
+type DumpFormat struct {
+	Name string
+	// Extension of the dump file, e.g. ".sql"; empty for formats writing a directory.
+	Extension string
+	// Directory formats write to DumpOptions.Directory instead of Stdout.
+	Directory bool
+	// Compressed formats are compressed by the dump tool itself, so sku does not compress them again.
+	Compressed bool
+}
+
+// DumpOptions configure a dump; see DumpingDatabaseEngine.
+type DumpOptions struct {
+	Format DumpFormat
+	// SingleTransaction dumps all tables in one consistent snapshot, without locking them (if the engine supports it).
+	SingleTransaction bool
+	// IncludeTables restricts the dump to these tables; empty means all tables.
+	IncludeTables []string
+	ExcludeTables []string
+	// Directory is the target of directory formats.
+	Directory string
+}
+
+// DumpingDatabaseEngine is implemented by engines supporting configurable dumps ("sku mysql dump", ...).
+type DumpingDatabaseEngine interface {
+	SqlDatabaseEngine
+	// DumpFormats returns the supported formats; the first one is the default.
+	DumpFormats() []DumpFormat
+	// SupportsSingleTransaction is true if DumpOptions.SingleTransaction has an effect; engines which always dump
+	// a consistent snapshot return false.
+	SupportsSingleTransaction() bool
+	// ConfigurableDumpCommand writes the dump to Stdout (or options.Directory for directory formats), and its
+	// progress to Stderr.
+	ConfigurableDumpCommand(endpoint Endpoint, options DumpOptions) (*exec.Cmd, error)
+}
+
+// FindDumpFormat looks up a dump format of the engine by name; an empty name means the default format.
+func FindDumpFormat(engine DumpingDatabaseEngine, name string) (DumpFormat, error) {
+	formats := engine.DumpFormats()
+	if len(name) == 0 {
+		return formats[0], nil
+	}
+	formatNames := make([]string, 0, len(formats))
+	for _, format := range formats {
+		if format.Name == name {
+			return format, nil
+		}
+		formatNames = append(formatNames, format.Name)
+	}
+	return DumpFormat{}, fmt.Errorf("the dump format %s is not supported for %s, use one of %v", name, engine.Name(), formatNames)
+}
+
+// ServerVersion returns the version of the database server, e.g. for the metadata of dumps.
+func (c *Connection) ServerVersion() (string, error) {
+	db, err := c.OpenSql()
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	var version string
+	// supported by MySQL, MariaDB and Postgres alike
+	if err = db.QueryRow("SELECT version()").Scan(&version); err != nil {
+		return "", fmt.Errorf("could not read the server version: %w", err)
+	}
+	return version, nil
+}
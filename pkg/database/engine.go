@@ -0,0 +1,109 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// ErrNotSupported is returned by engines for tooling they do not provide (e.g. restoring a Redis dump).
+var ErrNotSupported = errors.New("not supported by this database engine")
+
+// Credentials describe how to reach a database from within the cluster.
+type Credentials struct {
+	Host     string
+	Port     int
+	Name     string
+	User     string
+	Password string
+}
+
+// Endpoint is a database reachable from the local machine (usually at 127.0.0.1 through a Tunnel),
+// together with the credentials to log in. It has the fields of Credentials, but is a distinct type, so that
+// in-cluster credentials are not passed to local tools by accident.
+type Endpoint Credentials
+
+// ClientTool is a way to enter a database, e.g. a CLI or a GUI.
+type ClientTool struct {
+	Name        string
+	Description string
+	// Run starts the tool and returns after the user is finished with it. extraArgs are passed to CLI tools.
+	Run func(endpoint Endpoint, extraArgs []string) error
+}
+
+// DatabaseEngine knows everything engine-specific needed to connect to a database, enter it, dump and restore it.
+//
+// Engines register themselves via RegisterEngine; the commands are built generically on top of this interface.
+type DatabaseEngine interface {
+	// Name is the identifier used on the command line, e.g. "mysql".
+	Name() string
+	DefaultPort() int
+	// DSN is a connection URL for the given endpoint, e.g. for GUI tools.
+	DSN(endpoint Endpoint) string
+	// Ping returns nil as soon as the database at the endpoint accepts logins.
+	Ping(endpoint Endpoint) error
+	ClientTools() []ClientTool
+	// DumpCommand writes a backup of the database to targetFile.
+	DumpCommand(endpoint Endpoint, targetFile string) (*exec.Cmd, error)
+	// RestoreCommand imports a backup (as produced by DumpCommand) which is read from Stdin.
+	RestoreCommand(endpoint Endpoint) (*exec.Cmd, error)
+}
+
+// SqlDatabaseEngine is implemented by engines which can be accessed via database/sql.
+type SqlDatabaseEngine interface {
+	DatabaseEngine
+	OpenSql(endpoint Endpoint) (*sql.DB, error)
+}
+
+var engines = map[string]DatabaseEngine{}
+
+// RegisterEngine makes an engine available under its Name(). It is meant to be called from init().
+func RegisterEngine(engine DatabaseEngine) {
+	if _, exists := engines[engine.Name()]; exists {
+		panic("database engine registered twice: " + engine.Name())
+	}
+	engines[engine.Name()] = engine
+}
+
+// Engine looks up a registered engine by name.
+func Engine(name string) (DatabaseEngine, error) {
+	engine, ok := engines[name]
+	if !ok {
+		return nil, fmt.Errorf("database engine %s is not supported, use one of %s", name, strings.Join(EngineNames(), ", "))
+	}
+	return engine, nil
+}
+
+// EngineNames returns the names of all registered engines, sorted.
+func EngineNames() []string {
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FindClientTool looks up a client tool of the engine by name.
+func FindClientTool(engine DatabaseEngine, toolName string) (ClientTool, error) {
+	toolNames := make([]string, 0)
+	for _, tool := range engine.ClientTools() {
+		if tool.Name == toolName {
+			return tool, nil
+		}
+		toolNames = append(toolNames, tool.Name)
+	}
+	return ClientTool{}, fmt.Errorf("the tool %s is not supported for %s, use one of %s", toolName, engine.Name(), strings.Join(toolNames, ", "))
+}
+
+// ClientToolNames returns the names of all client tools of the engine, e.g. for completion.
+func ClientToolNames(engine DatabaseEngine) []string {
+	toolNames := make([]string, 0)
+	for _, tool := range engine.ClientTools() {
+		toolNames = append(toolNames, tool.Name)
+	}
+	return toolNames
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/logrusorgru/aurora/v3"
+)
+
+// how often a Forward checks whether the pod (and proxy container) it tunnels through is still running
+const forwardHealthCheckInterval = 5 * time.Second
+
+// the delay between reconnection attempts grows up to this
+const forwardMaxReconnectDelay = 30 * time.Second
+
+// Forward is a tunnel from a fixed local port through a Proxy to targetHost:targetPort, which is re-opened
+// when it breaks, e.g. because the proxy pod died or was replaced by a deployment.
+//
+// Close the forward when you are done with it.
+type Forward struct {
+	LocalPort  int
+	TargetHost string
+	TargetPort int
+
+	proxy     *Proxy
+	lock      sync.Mutex
+	tunnel    *Tunnel
+	stopChan  chan struct{}
+	closeOnce sync.Once
+}
+
+// StartForward opens the tunnel (on a free local port if localPort is 0), and keeps it open until Close().
+func StartForward(proxy *Proxy, localPort int, targetHost string, targetPort int) (*Forward, error) {
+	tunnel, err := proxy.OpenTunnel(localPort, targetHost, targetPort)
+	if err != nil {
+		return nil, fmt.Errorf("could not open tunnel to %s:%d: %w", targetHost, targetPort, err)
+	}
+
+	forward := &Forward{
+		LocalPort:  tunnel.LocalPort,
+		TargetHost: targetHost,
+		TargetPort: targetPort,
+		proxy:      proxy,
+		tunnel:     tunnel,
+		stopChan:   make(chan struct{}),
+	}
+	go forward.keepOpen()
+	return forward, nil
+}
+
+// Target is the host:port the forward leads to.
+func (f *Forward) Target() string {
+	return fmt.Sprintf("%s:%d", f.TargetHost, f.TargetPort)
+}
+
+// PodName is the pod the forward currently tunnels through; empty while reconnecting.
+func (f *Forward) PodName() string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.tunnel == nil {
+		return ""
+	}
+	return f.tunnel.PodName
+}
+
+// Close stops the forward, and removes the proxy pod (if any). It is safe to call Close multiple times.
+func (f *Forward) Close() error {
+	f.closeOnce.Do(func() {
+		close(f.stopChan)
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		if f.tunnel != nil {
+			f.tunnel.Close()
+		}
+	})
+	return nil
+}
+
+func (f *Forward) keepOpen() {
+	healthCheck := time.NewTicker(forwardHealthCheckInterval)
+	defer healthCheck.Stop()
+
+	for {
+		f.lock.Lock()
+		tunnel := f.tunnel
+		f.lock.Unlock()
+
+		select {
+		case <-f.stopChan:
+			return
+		case <-tunnel.Done():
+		case <-healthCheck.C:
+			if f.proxy.isHealthy(tunnel, f.TargetHost, f.TargetPort) {
+				continue
+			}
+		}
+
+		fmt.Printf("%v %s lost the tunnel to %s via pod %s; reconnecting\n", aurora.Yellow("WARNING:"), time.Now().Format("15:04:05"), f.Target(), tunnel.PodName)
+		f.lock.Lock()
+		f.tunnel = nil
+		f.lock.Unlock()
+		tunnel.Close()
+		// wait until the local port is released
+		for range tunnel.Done() {
+		}
+
+		if !f.reconnect() {
+			return
+		}
+	}
+}
+
+// reconnect re-opens the tunnel on the same local port, retrying with a growing delay; false if the forward was
+// closed meanwhile.
+func (f *Forward) reconnect() bool {
+	delay := 1 * time.Second
+	for {
+		tunnel, err := f.proxy.OpenTunnel(f.LocalPort, f.TargetHost, f.TargetPort)
+		if err == nil {
+			f.lock.Lock()
+			defer f.lock.Unlock()
+			select {
+			case <-f.stopChan:
+				tunnel.Close()
+				return false
+			default:
+			}
+			f.tunnel = tunnel
+			fmt.Printf("%v %s reconnected 127.0.0.1:%d to %s via pod %s\n", aurora.Green("OK:"), time.Now().Format("15:04:05"), f.LocalPort, f.Target(), tunnel.PodName)
+			return true
+		}
+		fmt.Printf("%v %s could not reconnect to %s (retrying in %s): %v\n", aurora.Yellow("WARNING:"), time.Now().Format("15:04:05"), f.Target(), delay, err)
+
+		select {
+		case <-f.stopChan:
+			return false
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > forwardMaxReconnectDelay {
+			delay = forwardMaxReconnectDelay
+		}
+	}
+}
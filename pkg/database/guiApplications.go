@@ -0,0 +1,185 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// guiApplication is a GUI client, and where to find it on the supported operating systems.
+type guiApplication struct {
+	Name string
+	// macApplication is the application name (or path) for "open -a"; empty if not available on Mac OS X.
+	macApplication string
+	// linuxExecutables are looked up in the PATH first (installed via packages, snaps, JetBrains Toolbox, ...).
+	linuxExecutables []string
+	// linuxPaths are glob patterns of executables and AppImages, starting with ~ for the home directory. If several
+	// files match, the last one (usually the newest version) is used.
+	linuxPaths []string
+	// linuxDesktopFiles are IDs of .desktop files, started via gtk-launch (e.g. for Flatpaks).
+	linuxDesktopFiles []string
+}
+
+var (
+	sequelAceApplication = guiApplication{
+		Name:           "Sequel Ace",
+		macApplication: "Sequel Ace",
+	}
+	beekeeperApplication = guiApplication{
+		Name:             "Beekeeper Studio",
+		macApplication:   "/Applications/Beekeeper Studio.app",
+		linuxExecutables: []string{"beekeeper-studio"},
+		linuxPaths: []string{
+			"/opt/Beekeeper Studio/beekeeper-studio",
+			"~/Applications/Beekeeper-Studio-*.AppImage",
+			"~/Downloads/Beekeeper-Studio-*.AppImage",
+		},
+		linuxDesktopFiles: []string{"beekeeper-studio", "io.beekeeperstudio.Studio"},
+	}
+	compassApplication = guiApplication{
+		Name:             "MongoDB Compass",
+		macApplication:   "MongoDB Compass",
+		linuxExecutables: []string{"mongodb-compass"},
+		linuxPaths: []string{
+			"~/Applications/MongoDB-Compass*.AppImage",
+		},
+		linuxDesktopFiles: []string{"mongodb-compass"},
+	}
+	dbeaverApplication = guiApplication{
+		Name:             "DBeaver",
+		macApplication:   "DBeaver",
+		linuxExecutables: []string{"dbeaver", "dbeaver-ce"},
+		linuxPaths: []string{
+			"/usr/share/dbeaver-ce/dbeaver",
+			"~/Applications/dbeaver/dbeaver",
+		},
+		linuxDesktopFiles: []string{"dbeaver-ce", "io.dbeaver.DBeaverCommunity"},
+	}
+	datagripApplication = guiApplication{
+		Name:             "DataGrip",
+		macApplication:   "DataGrip",
+		linuxExecutables: []string{"datagrip", "datagrip.sh"},
+		linuxPaths: []string{
+			"~/.local/share/JetBrains/Toolbox/scripts/datagrip",
+			"/opt/DataGrip*/bin/datagrip.sh",
+			"/opt/datagrip*/bin/datagrip.sh",
+		},
+		linuxDesktopFiles: []string{"jetbrains-datagrip"},
+	}
+)
+
+// the directories containing .desktop files, see the XDG Desktop Entry Specification
+var linuxDesktopFileDirectories = []string{
+	"~/.local/share/applications",
+	"/usr/local/share/applications",
+	"/usr/share/applications",
+	"/var/lib/snapd/desktop/applications",
+	"/var/lib/flatpak/exports/share/applications",
+	"~/.local/share/flatpak/exports/share/applications",
+}
+
+// open starts the application without waiting for it; document (a URL, file or directory; may be empty) is opened
+// in it, and args are passed as command line arguments.
+func (a guiApplication) open(document string, args []string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		if len(a.macApplication) == 0 {
+			return a.notAvailable()
+		}
+		openArgs := []string{"-a", a.macApplication}
+		if len(document) > 0 {
+			openArgs = append(openArgs, document)
+		}
+		if len(args) > 0 {
+			openArgs = append(append(openArgs, "--args"), args...)
+		}
+		return runInteractive("open", openArgs)
+
+	case "linux":
+		if len(a.linuxExecutables)+len(a.linuxPaths)+len(a.linuxDesktopFiles) == 0 {
+			return a.notAvailable()
+		}
+		if executable := a.findLinuxExecutable(); len(executable) > 0 {
+			if len(document) > 0 {
+				args = append(args, document)
+			}
+			return startDetached(executable, args)
+		}
+		// desktop files cannot receive arbitrary arguments; only documents
+		if desktopFile := a.findLinuxDesktopFile(); len(desktopFile) > 0 && len(args) == 0 {
+			launchArgs := []string{desktopFile}
+			if len(document) > 0 {
+				launchArgs = append(launchArgs, document)
+			}
+			return startDetached("gtk-launch", launchArgs)
+		}
+		// e.g. a mysql:// URL, if the desktop has a handler for it
+		if len(document) > 0 && len(args) == 0 {
+			return runInteractive("xdg-open", []string{document})
+		}
+		return a.notAvailable()
+
+	default:
+		return a.notAvailable()
+	}
+}
+
+func (a guiApplication) notAvailable() error {
+	if runtime.GOOS == "linux" && len(a.linuxExecutables) > 0 {
+		return fmt.Errorf("%s was not found; install it, or put its executable (%s) into the PATH", a.Name, a.linuxExecutables[0])
+	}
+	return fmt.Errorf("%s is not available on %s", a.Name, runtime.GOOS)
+}
+
+func (a guiApplication) findLinuxExecutable() string {
+	for _, executable := range a.linuxExecutables {
+		if path, err := exec.LookPath(executable); err == nil {
+			return path
+		}
+	}
+	for _, pattern := range a.linuxPaths {
+		matches, _ := filepath.Glob(expandHome(pattern))
+		sort.Strings(matches)
+		for i := len(matches) - 1; i >= 0; i-- {
+			if info, err := os.Stat(matches[i]); err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0 {
+				return matches[i]
+			}
+		}
+	}
+	return ""
+}
+
+func (a guiApplication) findLinuxDesktopFile() string {
+	for _, desktopFile := range a.linuxDesktopFiles {
+		for _, directory := range linuxDesktopFileDirectories {
+			if _, err := os.Stat(filepath.Join(expandHome(directory), desktopFile+".desktop")); err == nil {
+				return desktopFile
+			}
+		}
+	}
+	return ""
+}
+
+// startDetached starts a GUI application without waiting for it; its output is discarded, as it would mix with ours.
+func startDetached(command string, args []string) error {
+	cmd := exec.Command(command, args...)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not start %s: %w", command, err)
+	}
+	return cmd.Process.Release()
+}
+
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
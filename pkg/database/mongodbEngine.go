@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"time"
+
+	"github.com/logrusorgru/aurora/v3"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+type mongodbEngine struct{}
+
+func init() {
+	RegisterEngine(mongodbEngine{})
+}
+
+func (e mongodbEngine) Name() string {
+	return "mongodb"
+}
+
+func (e mongodbEngine) DefaultPort() int {
+	return 27017
+}
+
+func (e mongodbEngine) DSN(endpoint Endpoint) string {
+	dsn := url.URL{
+		Scheme: "mongodb",
+		Host:   fmt.Sprintf("%s:%d", endpoint.Host, endpoint.Port),
+		Path:   "/" + endpoint.Name,
+		// we are connected to a single replica set member through the tunnel; the other members
+		// announced by the server are not reachable from the local machine.
+		RawQuery: "directConnection=true",
+	}
+	if len(endpoint.User) > 0 {
+		dsn.User = url.UserPassword(endpoint.User, endpoint.Password)
+	}
+	return dsn.String()
+}
+
+func (e mongodbEngine) Ping(endpoint Endpoint) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(e.DSN(endpoint)))
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+
+	return client.Ping(ctx, readpref.Primary())
+}
+
+func (e mongodbEngine) ClientTools() []ClientTool {
+	return []ClientTool{
+		{
+			Name:        "mongosh",
+			Description: "the MongoDB shell mongosh",
+			Run: func(endpoint Endpoint, extraArgs []string) error {
+				return runInteractive("mongosh", append([]string{e.DSN(endpoint)}, extraArgs...))
+			},
+		},
+		{
+			Name:        "compass",
+			Description: "MongoDB Compass (GUI)",
+			Run: func(endpoint Endpoint, extraArgs []string) error {
+				err := compassApplication.open("", nil)
+				if err != nil {
+					return err
+				}
+
+				fmt.Println(aurora.Bold("For MongoDB Compass, you need to paste the following connection string:"))
+				fmt.Println(aurora.Green(e.DSN(endpoint)))
+				waitUntilInterrupted()
+				return nil
+			},
+		},
+		{
+			Name:        "cli",
+			Description: "the legacy mongo shell",
+			Run: func(endpoint Endpoint, extraArgs []string) error {
+				return runInteractive("mongo", append([]string{e.DSN(endpoint)}, extraArgs...))
+			},
+		},
+	}
+}
+
+func (e mongodbEngine) DumpCommand(endpoint Endpoint, targetFile string) (*exec.Cmd, error) {
+	return exec.Command("mongodump", fmt.Sprintf("--uri=%s", e.DSN(endpoint)), fmt.Sprintf("--archive=%s", targetFile)), nil
+}
+
+func (e mongodbEngine) RestoreCommand(endpoint Endpoint) (*exec.Cmd, error) {
+	// --archive without a file name reads from Stdin
+	return exec.Command("mongorestore", fmt.Sprintf("--uri=%s", e.DSN(endpoint)), "--archive"), nil
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strconv"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type mysqlEngine struct{}
+
+func init() {
+	RegisterEngine(mysqlEngine{})
+}
+
+func (e mysqlEngine) Name() string {
+	return "mysql"
+}
+
+func (e mysqlEngine) DefaultPort() int {
+	return 3306
+}
+
+func (e mysqlEngine) DSN(endpoint Endpoint) string {
+	dsn := url.URL{
+		Scheme: "mysql",
+		User:   url.UserPassword(endpoint.User, endpoint.Password),
+		Host:   fmt.Sprintf("%s:%d", endpoint.Host, endpoint.Port),
+		Path:   "/" + endpoint.Name,
+	}
+	return dsn.String()
+}
+
+func (e mysqlEngine) OpenSql(endpoint Endpoint) (*sql.DB, error) {
+	return sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", endpoint.User, endpoint.Password, endpoint.Host, endpoint.Port, endpoint.Name))
+}
+
+func (e mysqlEngine) Ping(endpoint Endpoint) error {
+	return pingSql(e, endpoint)
+}
+
+func (e mysqlEngine) ClientTools() []ClientTool {
+	return []ClientTool{
+		usqlTool(e),
+		{
+			Name:        "cli",
+			Description: "the mysql command line client",
+			Run: func(endpoint Endpoint, extraArgs []string) error {
+				return runInteractive("mysql", append(e.cliConnectionArgs(endpoint), extraArgs...))
+			},
+		},
+		{
+			Name:        "mycli",
+			Description: "mycli, see https://www.mycli.net/",
+			Run: func(endpoint Endpoint, extraArgs []string) error {
+				mycliArgs := []string{
+					"--host", endpoint.Host,
+					"--port", strconv.Itoa(endpoint.Port),
+					"--user", endpoint.User,
+					"--password", endpoint.Password,
+					endpoint.Name,
+				}
+				return runInteractive("mycli", append(mycliArgs, extraArgs...))
+			},
+		},
+		{
+			Name:        "sequelace",
+			Description: "Sequel Ace (GUI, Mac OS X only)",
+			Run: func(endpoint Endpoint, extraArgs []string) error {
+				err := sequelAceApplication.open(e.DSN(endpoint), nil)
+				if err != nil {
+					return err
+				}
+				waitUntilInterrupted()
+				return nil
+			},
+		},
+		beekeeperTool(e),
+		dbeaverTool(e, "mysql"),
+		datagripTool(e, "mysql", "mysql.8"),
+	}
+}
+
+func (e mysqlEngine) DumpCommand(endpoint Endpoint, targetFile string) (*exec.Cmd, error) {
+	dumpArgs := e.cliConnectionArgs(endpoint)
+	// the database name is always the last argument
+	dumpArgs = append(dumpArgs[:len(dumpArgs)-1], fmt.Sprintf("--result-file=%s", targetFile), endpoint.Name)
+	return exec.Command("mysqldump", dumpArgs...), nil
+}
+
+func (e mysqlEngine) DumpFormats() []DumpFormat {
+	return []DumpFormat{
+		{Name: "plain", Extension: ".sql"},
+	}
+}
+
+func (e mysqlEngine) SupportsSingleTransaction() bool {
+	return true
+}
+
+func (e mysqlEngine) ConfigurableDumpCommand(endpoint Endpoint, options DumpOptions) (*exec.Cmd, error) {
+	dumpArgs := e.cliConnectionArgs(endpoint)
+	// the database name (followed by the tables to include) must be the last arguments
+	dumpArgs = append(dumpArgs[:len(dumpArgs)-1], "--verbose", "--routines", "--triggers")
+	if options.SingleTransaction {
+		dumpArgs = append(dumpArgs, "--single-transaction", "--quick")
+	}
+	for _, table := range options.ExcludeTables {
+		dumpArgs = append(dumpArgs, fmt.Sprintf("--ignore-table=%s.%s", endpoint.Name, table))
+	}
+	dumpArgs = append(append(dumpArgs, endpoint.Name), options.IncludeTables...)
+	return exec.Command("mysqldump", dumpArgs...), nil
+}
+
+func (e mysqlEngine) RestoreCommand(endpoint Endpoint) (*exec.Cmd, error) {
+	return exec.Command("mysql", e.cliConnectionArgs(endpoint)...), nil
+}
+
+func (e mysqlEngine) cliConnectionArgs(endpoint Endpoint) []string {
+	return []string{
+		fmt.Sprintf("--host=%s", endpoint.Host),
+		fmt.Sprintf("--port=%d", endpoint.Port),
+		fmt.Sprintf("--user=%s", endpoint.User),
+		fmt.Sprintf("--password=%s", endpoint.Password),
+		endpoint.Name,
+	}
+}
+
+// pingSql opens a short-lived database/sql connection to check whether the database is available.
+func pingSql(engine SqlDatabaseEngine, endpoint Endpoint) error {
+	db, err := engine.OpenSql(endpoint)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Ping()
+}
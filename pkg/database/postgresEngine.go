@@ -0,0 +1,151 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+
+	_ "github.com/jackc/pgx/v4/stdlib"
+)
+
+type postgresEngine struct{}
+
+func init() {
+	RegisterEngine(postgresEngine{})
+}
+
+func (e postgresEngine) Name() string {
+	return "postgres"
+}
+
+func (e postgresEngine) DefaultPort() int {
+	return 5432
+}
+
+func (e postgresEngine) DSN(endpoint Endpoint) string {
+	dsn := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(endpoint.User, endpoint.Password),
+		Host:   fmt.Sprintf("%s:%d", endpoint.Host, endpoint.Port),
+		Path:   "/" + endpoint.Name,
+	}
+	return dsn.String()
+}
+
+func (e postgresEngine) OpenSql(endpoint Endpoint) (*sql.DB, error) {
+	// see https://github.com/jackc/pgx/blob/master/stdlib/sql.go
+	return sql.Open("pgx", e.DSN(endpoint))
+}
+
+func (e postgresEngine) Ping(endpoint Endpoint) error {
+	return pingSql(e, endpoint)
+}
+
+func (e postgresEngine) ClientTools() []ClientTool {
+	return []ClientTool{
+		usqlTool(e),
+		{
+			Name:        "cli",
+			Description: "the psql command line client",
+			Run: func(endpoint Endpoint, extraArgs []string) error {
+				psqlArgs := []string{
+					fmt.Sprintf("--host=%s", endpoint.Host),
+					fmt.Sprintf("--port=%d", endpoint.Port),
+					fmt.Sprintf("--username=%s", endpoint.User),
+					endpoint.Name,
+				}
+				return runInteractive("psql", append(psqlArgs, extraArgs...), e.passwordEnv(endpoint))
+			},
+		},
+		{
+			Name:        "pgcli",
+			Description: "pgcli, see https://www.pgcli.com/",
+			Run: func(endpoint Endpoint, extraArgs []string) error {
+				pgcliArgs := []string{
+					"--host", endpoint.Host,
+					"--port", strconv.Itoa(endpoint.Port),
+					"--user", endpoint.User,
+					endpoint.Name,
+				}
+				return runInteractive("pgcli", append(pgcliArgs, extraArgs...), e.passwordEnv(endpoint))
+			},
+		},
+		beekeeperTool(e),
+		dbeaverTool(e, "postgresql"),
+		datagripTool(e, "postgresql", "postgresql"),
+	}
+}
+
+func (e postgresEngine) DumpCommand(endpoint Endpoint, targetFile string) (*exec.Cmd, error) {
+	pgDump := exec.Command(
+		"pg_dump",
+		"-h", endpoint.Host,
+		"-p", strconv.Itoa(endpoint.Port),
+		"-U", endpoint.User,
+		"--format=plain",
+		"--no-owner",
+		"--no-privileges",
+		"-f", targetFile,
+		endpoint.Name,
+	)
+	pgDump.Env = append(os.Environ(), e.passwordEnv(endpoint))
+	return pgDump, nil
+}
+
+func (e postgresEngine) DumpFormats() []DumpFormat {
+	return []DumpFormat{
+		{Name: "plain", Extension: ".sql"},
+		// for pg_restore; compressed by pg_dump
+		{Name: "custom", Extension: ".dump", Compressed: true},
+		{Name: "directory", Directory: true, Compressed: true},
+	}
+}
+
+// SupportsSingleTransaction is false, as pg_dump always dumps a consistent snapshot.
+func (e postgresEngine) SupportsSingleTransaction() bool {
+	return false
+}
+
+// ConfigurableDumpCommand runs pg_dump; SingleTransaction is ignored (see SupportsSingleTransaction).
+func (e postgresEngine) ConfigurableDumpCommand(endpoint Endpoint, options DumpOptions) (*exec.Cmd, error) {
+	dumpArgs := []string{
+		"-h", endpoint.Host,
+		"-p", strconv.Itoa(endpoint.Port),
+		"-U", endpoint.User,
+		"--format=" + options.Format.Name,
+		"--no-owner",
+		"--no-privileges",
+		"--verbose",
+	}
+	for _, table := range options.IncludeTables {
+		dumpArgs = append(dumpArgs, "--table="+table)
+	}
+	for _, table := range options.ExcludeTables {
+		dumpArgs = append(dumpArgs, "--exclude-table="+table)
+	}
+	if options.Format.Directory {
+		dumpArgs = append(dumpArgs, "--file="+options.Directory)
+	}
+	pgDump := exec.Command("pg_dump", append(dumpArgs, endpoint.Name)...)
+	pgDump.Env = append(os.Environ(), e.passwordEnv(endpoint))
+	return pgDump, nil
+}
+
+func (e postgresEngine) RestoreCommand(endpoint Endpoint) (*exec.Cmd, error) {
+	psql := exec.Command(
+		"psql",
+		"-h", endpoint.Host,
+		"-p", strconv.Itoa(endpoint.Port),
+		"-U", endpoint.User,
+		endpoint.Name,
+	)
+	psql.Env = append(os.Environ(), e.passwordEnv(endpoint))
+	return psql, nil
+}
+
+func (e postgresEngine) passwordEnv(endpoint Endpoint) string {
+	return fmt.Sprintf("PGPASSWORD=%s", endpoint.Password)
+}
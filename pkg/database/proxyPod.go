@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sandstorm/sku/pkg/kubernetes"
+	clientV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+// ProxyPodLabel is set on every proxy pod sku creates.
+const ProxyPodLabel = "sku.sandstorm.de/proxy"
+
+// proxy pods are removed when the tunnel is closed; this deadline is a safety net in case sku gets killed.
+const proxyPodActiveDeadlineSeconds = 12 * 60 * 60
+
+// CreateProxyPod starts a socat pod forwarding to targetHost:targetPort, carrying the pod labels of the given
+// workload so that NetworkPolicies apply in the same way. It waits until the pod is running, and returns
+// the pod name and the port socat listens on.
+func CreateProxyPod(namespace string, workload kubernetes.Workload, targetHost string, targetPort int) (string, int, error) {
+	listenPort := proxyListenPort(targetHost, targetPort)
+
+	labels := make(map[string]string, len(workload.PodTemplate.Labels)+1)
+	for k, v := range workload.PodTemplate.Labels {
+		labels[k] = v
+	}
+	labels[ProxyPodLabel] = "true"
+
+	pod := &clientV1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "sku-proxy-",
+			Namespace:    namespace,
+			Labels:       labels,
+			Annotations: map[string]string{
+				proxyTargetEnvVar: fmt.Sprintf("%s:%d", targetHost, targetPort),
+			},
+		},
+		Spec: clientV1.PodSpec{
+			RestartPolicy:                 clientV1.RestartPolicyNever,
+			ActiveDeadlineSeconds:         pointer.Int64Ptr(proxyPodActiveDeadlineSeconds),
+			AutomountServiceAccountToken:  pointer.BoolPtr(false),
+			TerminationGracePeriodSeconds: pointer.Int64Ptr(0),
+			Containers: []clientV1.Container{
+				{
+					Name:            "socat",
+					Image:           proxyImage,
+					ImagePullPolicy: clientV1.PullAlways,
+					Args: []string{
+						fmt.Sprintf("tcp-listen:%d,fork,reuseaddr", listenPort),
+						fmt.Sprintf("tcp-connect:%s:%d", targetHost, targetPort),
+					},
+					// As we copy the workload labels, Services would route traffic to this pod as well.
+					// A never-succeeding readiness probe keeps the pod out of all Service endpoints;
+					// port-forwarding works nevertheless.
+					ReadinessProbe: &clientV1.Probe{
+						Handler: clientV1.Handler{
+							Exec: &clientV1.ExecAction{Command: []string{"/bin/false"}},
+						},
+						PeriodSeconds: 3600,
+					},
+					// restrictive, so that the pod is admitted by strict pod security policies.
+					SecurityContext: &clientV1.SecurityContext{
+						RunAsNonRoot:             pointer.BoolPtr(true),
+						RunAsUser:                pointer.Int64Ptr(65534),
+						AllowPrivilegeEscalation: pointer.BoolPtr(false),
+						ReadOnlyRootFilesystem:   pointer.BoolPtr(true),
+						Capabilities: &clientV1.Capabilities{
+							Drop: []clientV1.Capability{"ALL"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	pod, err := kubernetes.KubernetesClientset().CoreV1().Pods(namespace).Create(context.Background(), pod, metav1.CreateOptions{})
+	if err != nil {
+		return "", 0, fmt.Errorf("could not create proxy pod: %w", err)
+	}
+	fmt.Printf("  - Created proxy pod %s with the labels of %s\n", pod.Name, workload)
+
+	err = waitForPodRunning(namespace, pod.Name)
+	if err != nil {
+		DeleteProxyPod(namespace, pod.Name)
+		return "", 0, err
+	}
+
+	return pod.Name, listenPort, nil
+}
+
+// DeleteProxyPod removes a proxy pod created by CreateProxyPod.
+func DeleteProxyPod(namespace, podName string) {
+	err := kubernetes.KubernetesClientset().CoreV1().Pods(namespace).Delete(context.Background(), podName, metav1.DeleteOptions{
+		GracePeriodSeconds: pointer.Int64Ptr(0),
+	})
+	if err != nil {
+		fmt.Printf("  - WARNING: could not delete proxy pod %s: %v\n", podName, err)
+		return
+	}
+	fmt.Printf("  - Deleted proxy pod %s\n", podName)
+}
+
+func waitForPodRunning(namespace, podName string) error {
+	fmt.Printf("  - Waiting for pod %s to be running\n", podName)
+	deadline := time.Now().Add(proxyStartupTimeout)
+	for time.Now().Before(deadline) {
+		pod, err := kubernetes.KubernetesClientset().CoreV1().Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("could not fetch pod %s: %w", podName, err)
+		}
+
+		switch pod.Status.Phase {
+		case clientV1.PodRunning:
+			return nil
+		case clientV1.PodFailed, clientV1.PodSucceeded:
+			return fmt.Errorf("pod %s stopped: %s %s", podName, pod.Status.Reason, pod.Status.Message)
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Waiting != nil && isFatalWaitingReason(status.State.Waiting.Reason) {
+				return fmt.Errorf("pod %s cannot start: %s %s", podName, status.State.Waiting.Reason, status.State.Waiting.Message)
+			}
+		}
+
+		time.Sleep(1 * time.Second)
+	}
+
+	return fmt.Errorf("pod %s was not running after %s", podName, proxyStartupTimeout)
+}
@@ -0,0 +1,227 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/logrusorgru/aurora/v3"
+	"github.com/sandstorm/sku/pkg/kubernetes"
+	clientV1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProxyStrategy defines how we reach a host (e.g. a database) which is only reachable from inside the cluster.
+type ProxyStrategy string
+
+const (
+	// ProxyStrategyDebug adds a socat debug container to an already-running pod.
+	ProxyStrategyDebug ProxyStrategy = "debug"
+	// ProxyStrategyPod starts a short-lived socat pod with the labels of a chosen workload,
+	// so that NetworkPolicies still match. The pod is deleted when the tunnel is closed.
+	ProxyStrategyPod ProxyStrategy = "pod"
+	// ProxyStrategyDirect port-forwards directly to the selected pod, e.g. the pod of a database StatefulSet.
+	ProxyStrategyDirect ProxyStrategy = "direct"
+)
+
+// ProxyStrategies lists all supported strategies, e.g. for flag completion.
+var ProxyStrategies = []string{string(ProxyStrategyDebug), string(ProxyStrategyPod), string(ProxyStrategyDirect)}
+
+func ParseProxyStrategy(proxyStrategy string) (ProxyStrategy, error) {
+	for _, s := range ProxyStrategies {
+		if s == proxyStrategy {
+			return ProxyStrategy(s), nil
+		}
+	}
+	return "", fmt.Errorf("unknown proxy strategy %s, use one of %s", proxyStrategy, strings.Join(ProxyStrategies, ", "))
+}
+
+// Proxy is the way into the cluster chosen for a proxy strategy, i.e. the pod (or the workload whose labels
+// a proxy pod carries). It opens any number of tunnels, and re-opens them without asking again.
+type Proxy struct {
+	Strategy  ProxyStrategy
+	Namespace string
+
+	// for the debug and direct strategies; owner ("Kind/name") is used to find a replacement if the pod is gone.
+	// Forwards sharing the proxy reconnect concurrently; lock guards podName.
+	lock    sync.Mutex
+	podName string
+	owner   string
+	// for the pod strategy
+	workload kubernetes.Workload
+}
+
+// SelectProxy asks for the pod or workload needed by the strategy (unless given via --pod or --workload).
+func SelectProxy(proxyStrategy ProxyStrategy) (*Proxy, error) {
+	proxy := &Proxy{Strategy: proxyStrategy, Namespace: kubernetes.CurrentNamespace()}
+	var err error
+
+	switch proxyStrategy {
+	case ProxyStrategyDirect:
+		proxy.podName, err = kubernetes.SelectPod("Please select the Pod to connect to directly")
+	case ProxyStrategyPod:
+		proxy.workload, err = kubernetes.SelectWorkload("Please select a workload whose labels the proxy Pod should carry")
+	default:
+		proxy.podName, err = kubernetes.SelectPod("Please select a Pod to use as a proxy into the cluster")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(proxy.podName) > 0 {
+		pod, err := kubernetes.KubernetesClientset().CoreV1().Pods(proxy.Namespace).Get(context.Background(), proxy.podName, metav1.GetOptions{})
+		if err == nil {
+			proxy.owner = kubernetes.PodOwners(proxy.Namespace, []clientV1.Pod{*pod})[pod.Name]
+		}
+	}
+	return proxy, nil
+}
+
+// ProxySpec describes a Proxy, so that it can be re-created (e.g. in the tunnel daemon) without asking again.
+type ProxySpec struct {
+	Strategy  ProxyStrategy
+	Namespace string
+	Pod       string
+	Owner     string
+	// Workload is "Kind/name" of the workload whose labels the proxy pods carry
+	Workload string
+}
+
+// Spec returns the description of the proxy.
+func (p *Proxy) Spec() ProxySpec {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	spec := ProxySpec{Strategy: p.Strategy, Namespace: p.Namespace, Pod: p.podName, Owner: p.owner}
+	if p.Strategy == ProxyStrategyPod {
+		spec.Workload = p.workload.String()
+	}
+	return spec
+}
+
+// Proxy re-creates the described proxy; for the pod strategy, the workload is fetched again.
+func (s ProxySpec) Proxy() (*Proxy, error) {
+	proxy := &Proxy{Strategy: s.Strategy, Namespace: s.Namespace, podName: s.Pod, owner: s.Owner}
+	if s.Strategy != ProxyStrategyPod {
+		return proxy, nil
+	}
+
+	workloads, err := kubernetes.ListWorkloads(s.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("workloads could not be fetched: %w", err)
+	}
+	for _, workload := range workloads {
+		if workload.String() == s.Workload {
+			proxy.workload = workload
+			return proxy, nil
+		}
+	}
+	return nil, fmt.Errorf("workload %s not found in namespace %s", s.Workload, s.Namespace)
+}
+
+// OpenTunnel opens a tunnel from localPort (a free port if 0) through the proxy to targetHost:targetPort.
+func (p *Proxy) OpenTunnel(localPort int, targetHost string, targetPort int) (*Tunnel, error) {
+	switch p.Strategy {
+	case ProxyStrategyDirect:
+		podName, err := p.runningPod()
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("  - Connecting directly to port %d of pod %s\n", targetPort, aurora.Green(podName))
+
+		return OpenTunnel(p.Namespace, podName, localPort, targetPort)
+
+	case ProxyStrategyPod:
+		podName, proxyPort, err := CreateProxyPod(p.Namespace, p.workload, targetHost, targetPort)
+		if err != nil {
+			return nil, err
+		}
+
+		tunnel, err := OpenTunnel(p.Namespace, podName, localPort, proxyPort)
+		if err != nil {
+			DeleteProxyPod(p.Namespace, podName)
+			return nil, err
+		}
+		tunnel.onClose(func() {
+			DeleteProxyPod(p.Namespace, podName)
+		})
+		return tunnel, nil
+
+	default:
+		podName, err := p.runningPod()
+		if err != nil {
+			return nil, err
+		}
+		proxyPort, err := EnsureDebugContainerProxy(p.Namespace, podName, targetHost, targetPort)
+		if err != nil {
+			return nil, fmt.Errorf("could not start the proxy debug container: %w", err)
+		}
+
+		return OpenTunnel(p.Namespace, podName, localPort, proxyPort)
+	}
+}
+
+// runningPod returns the selected pod; switching to another running pod of the same workload if it is gone,
+// e.g. after a deployment. The lock is held meanwhile, so that forwards reconnecting at the same time all
+// switch to the same pod.
+func (p *Proxy) runningPod() (string, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	pod, err := kubernetes.KubernetesClientset().CoreV1().Pods(p.Namespace).Get(context.Background(), p.podName, metav1.GetOptions{})
+	if err == nil && isPodAlive(pod) {
+		return p.podName, nil
+	}
+	if len(p.owner) == 0 {
+		return "", fmt.Errorf("%w: pod %s is not running anymore", kubernetes.ErrPodNotFound, p.podName)
+	}
+
+	pods, err := kubernetes.KubernetesClientset().CoreV1().Pods(p.Namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("pods could not be listed: %w", err)
+	}
+	owners := kubernetes.PodOwners(p.Namespace, pods.Items)
+	for i := range pods.Items {
+		if owners[pods.Items[i].Name] == p.owner && kubernetes.IsPodReady(&pods.Items[i]) && pods.Items[i].DeletionTimestamp == nil {
+			fmt.Printf("  - Pod %s is not running anymore, using pod %s of %s instead\n", p.podName, aurora.Green(pods.Items[i].Name), p.owner)
+			p.podName = pods.Items[i].Name
+			return p.podName, nil
+		}
+	}
+	return "", fmt.Errorf("%w: pod %s is not running anymore, and %s has no other ready pod", kubernetes.ErrPodNotFound, p.podName, p.owner)
+}
+
+// isHealthy returns true unless the pod the tunnel leads to is gone, or (for the debug strategy) its proxy
+// container for the target has terminated. Errors reaching the API server do not count as unhealthy.
+func (p *Proxy) isHealthy(tunnel *Tunnel, targetHost string, targetPort int) bool {
+	pod, err := kubernetes.KubernetesClientset().CoreV1().Pods(tunnel.Namespace).Get(context.Background(), tunnel.PodName, metav1.GetOptions{})
+	if err != nil {
+		return !apierrors.IsNotFound(err)
+	}
+	if !isPodAlive(pod) {
+		return false
+	}
+	if p.Strategy == ProxyStrategyDebug {
+		return hasRunningProxyContainer(pod, targetHost, targetPort)
+	}
+	return true
+}
+
+// isPodAlive returns true if the pod is running and not being deleted.
+func isPodAlive(pod *clientV1.Pod) bool {
+	return pod.Status.Phase == clientV1.PodRunning && pod.DeletionTimestamp == nil
+}
+
+func describeProxyStrategy(proxyStrategy ProxyStrategy) string {
+	switch proxyStrategy {
+	case ProxyStrategyDirect:
+		return "We will connect by port-forwarding directly to a Pod in the namespace."
+	case ProxyStrategyPod:
+		return "We will connect through a short-lived proxy Pod carrying the labels of a workload\n" +
+			"   in the namespace, so that we won't have problems with Network Policies etc."
+	default:
+		return "We will connect to the database by adding a Debug Container to an already-running Pod\n" +
+			"   in the namespace, so that we won't have problems with Network Policies etc."
+	}
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type redisEngine struct{}
+
+func init() {
+	RegisterEngine(redisEngine{})
+}
+
+func (e redisEngine) Name() string {
+	return "redis"
+}
+
+func (e redisEngine) DefaultPort() int {
+	return 6379
+}
+
+// DSN uses the database name as Redis database index.
+func (e redisEngine) DSN(endpoint Endpoint) string {
+	dsn := url.URL{
+		Scheme: "redis",
+		Host:   fmt.Sprintf("%s:%d", endpoint.Host, endpoint.Port),
+		Path:   "/" + e.databaseIndex(endpoint),
+	}
+	if len(endpoint.Password) > 0 {
+		dsn.User = url.UserPassword(endpoint.User, endpoint.Password)
+	}
+	return dsn.String()
+}
+
+// Ping speaks the Redis protocol (RESP) directly: AUTH (if needed), then PING, expecting PONG.
+func (e redisEngine) Ping(endpoint Endpoint) error {
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", endpoint.Host, endpoint.Port), 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	if len(endpoint.Password) > 0 {
+		authCommand := []string{"AUTH", endpoint.Password}
+		if len(endpoint.User) > 0 {
+			authCommand = []string{"AUTH", endpoint.User, endpoint.Password}
+		}
+		if _, err := redisCommand(conn, reader, authCommand...); err != nil {
+			return fmt.Errorf("AUTH failed: %w", err)
+		}
+	}
+
+	reply, err := redisCommand(conn, reader, "PING")
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("unexpected reply to PING: %s", reply)
+	}
+	return nil
+}
+
+// redisCommand sends a command and reads a simple string reply.
+func redisCommand(conn net.Conn, reader *bufio.Reader, args ...string) (string, error) {
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := conn.Write([]byte(command.String())); err != nil {
+		return "", err
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, "-") {
+		return "", fmt.Errorf("%s", line[1:])
+	}
+	return strings.TrimPrefix(line, "+"), nil
+}
+
+func (e redisEngine) ClientTools() []ClientTool {
+	return []ClientTool{
+		{
+			Name:        "redis-cli",
+			Description: "the redis-cli command line client",
+			Run: func(endpoint Endpoint, extraArgs []string) error {
+				return runInteractive("redis-cli", append(e.cliConnectionArgs(endpoint), extraArgs...))
+			},
+		},
+		{
+			Name:        "iredis",
+			Description: "iredis, see https://iredis.io/",
+			Run: func(endpoint Endpoint, extraArgs []string) error {
+				// iredis does not support ACL users on the command line, but via URL.
+				return runInteractive("iredis", append([]string{"--url", e.DSN(endpoint)}, extraArgs...))
+			},
+		},
+	}
+}
+
+func (e redisEngine) DumpCommand(endpoint Endpoint, targetFile string) (*exec.Cmd, error) {
+	return exec.Command("redis-cli", append(e.cliConnectionArgs(endpoint), "--rdb", targetFile)...), nil
+}
+
+func (e redisEngine) RestoreCommand(endpoint Endpoint) (*exec.Cmd, error) {
+	// an RDB file can only be loaded when the Redis server starts.
+	return nil, ErrNotSupported
+}
+
+func (e redisEngine) cliConnectionArgs(endpoint Endpoint) []string {
+	cliArgs := []string{
+		"-h", endpoint.Host,
+		"-p", strconv.Itoa(endpoint.Port),
+		"-n", e.databaseIndex(endpoint),
+	}
+	if len(endpoint.User) > 0 {
+		cliArgs = append(cliArgs, "--user", endpoint.User)
+	}
+	if len(endpoint.Password) > 0 {
+		cliArgs = append(cliArgs, "--pass", endpoint.Password, "--no-auth-warning")
+	}
+	return cliArgs
+}
+
+func (e redisEngine) databaseIndex(endpoint Endpoint) string {
+	if len(endpoint.Name) == 0 {
+		return "0"
+	}
+	return endpoint.Name
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/phayes/freeport"
+	"github.com/sandstorm/sku/pkg/kubernetes"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// Tunnel is an in-process port-forwarding from a local port to a port of a pod,
+// built on the SPDY port-forwarder of client-go (the same mechanism `kubectl port-forward` uses).
+//
+// Close the tunnel when you are done with it.
+type Tunnel struct {
+	LocalPort int
+	PodName   string
+	Namespace string
+
+	stopChan  chan struct{}
+	doneChan  chan error
+	closeOnce sync.Once
+	// run after the port-forwarding has been stopped, e.g. to remove a dedicated proxy pod.
+	cleanups []func()
+}
+
+// OpenTunnel forwards localPort to remotePort of the given pod, and waits until the forwarding is ready.
+// If localPort is 0, a free local port is chosen.
+func OpenTunnel(namespace, podName string, localPort, remotePort int) (*Tunnel, error) {
+	if localPort == 0 {
+		var err error
+		localPort, err = freeport.GetFreePort()
+		if err != nil {
+			return nil, fmt.Errorf("did not find a free port: %w", err)
+		}
+	}
+
+	roundTripper, upgrader, err := spdy.RoundTripperFor(kubernetes.KubernetesRestConfig())
+	if err != nil {
+		return nil, fmt.Errorf("could not create SPDY round tripper: %w", err)
+	}
+
+	portForwardUrl := kubernetes.KubernetesClientset().CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(podName).
+		SubResource("portforward").
+		URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: roundTripper}, http.MethodPost, portForwardUrl)
+
+	tunnel := &Tunnel{
+		LocalPort: localPort,
+		PodName:   podName,
+		Namespace: namespace,
+		stopChan:  make(chan struct{}),
+		doneChan:  make(chan error, 1),
+	}
+	readyChan := make(chan struct{})
+
+	// "Forwarding from ..." and "Handling connection for ..." would only clutter the output.
+	forwarder, err := portforward.NewOnAddresses(
+		dialer,
+		[]string{"127.0.0.1"},
+		[]string{fmt.Sprintf("%d:%d", localPort, remotePort)},
+		tunnel.stopChan,
+		readyChan,
+		ioutil.Discard,
+		os.Stderr,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not create port-forward to pod %s: %w", podName, err)
+	}
+
+	go func() {
+		tunnel.doneChan <- forwarder.ForwardPorts()
+		close(tunnel.doneChan)
+	}()
+
+	select {
+	case <-readyChan:
+		return tunnel, nil
+	case err := <-tunnel.doneChan:
+		tunnel.Close()
+		if err == nil {
+			err = fmt.Errorf("port-forward stopped before it was ready")
+		}
+		return nil, fmt.Errorf("could not port-forward to pod %s: %w", podName, err)
+	}
+}
+
+// Done is closed (after an optional error has been sent) as soon as the port-forwarding stopped,
+// e.g. because the connection to the pod was lost or Close() was called.
+func (t *Tunnel) Done() <-chan error {
+	return t.doneChan
+}
+
+// Close stops the port-forwarding. It is safe to call Close multiple times.
+func (t *Tunnel) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.stopChan)
+		for _, cleanup := range t.cleanups {
+			cleanup()
+		}
+	})
+	return nil
+}
+
+func (t *Tunnel) onClose(cleanup func()) {
+	t.cleanups = append(t.cleanups, cleanup)
+}
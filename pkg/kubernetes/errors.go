@@ -0,0 +1,29 @@
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The errors returned by this package are wrapped around these; check them via errors.Is.
+var (
+	ErrContextNotFound   = errors.New("context not found")
+	ErrNamespaceNotFound = errors.New("namespace not found")
+	ErrSecretFetch       = errors.New("secret could not be fetched")
+	ErrConfigMapFetch    = errors.New("configmap could not be fetched")
+	ErrPodNotFound       = errors.New("no matching running pod found")
+	// ErrProtected is returned if a destructive operation in a protected context was not confirmed explicitly.
+	ErrProtected = errors.New("refusing a destructive operation in a protected context")
+	// ErrPromptAborted is returned if the user cancelled a selection (e.g. via Ctrl-C).
+	ErrPromptAborted = errors.New("prompt aborted")
+)
+
+// RemoteExitError is returned if a command run in a container exits with a non-zero code; sku exits with the same code.
+type RemoteExitError struct {
+	Pod  string
+	Code int
+}
+
+func (e RemoteExitError) Error() string {
+	return fmt.Sprintf("command in pod %s exited with code %d", e.Pod, e.Code)
+}
@@ -0,0 +1,150 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/dop251/goja"
+	"github.com/logrusorgru/aurora"
+	"github.com/sandstorm/sku/pkg/utility"
+	clientV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EvalContext evaluates "eval:" expressions (see Eval). Create one per set of expressions belonging together
+// (e.g. the credentials of one database): fetched Secrets, ConfigMaps, pods and resources, and the choices of
+// selectInteractively() are cached, so the expressions see the same state and the user is asked only once.
+//
+// An EvalContext can be used concurrently; evaluations of the same context run one after the other.
+type EvalContext struct {
+	mutex sync.Mutex
+	vm    *goja.Runtime
+	// the expression being evaluated; and the error of a Go callback which aborted it
+	parameter   string
+	callbackErr error
+
+	secrets            map[resourceKey]map[string]string
+	configMaps         map[resourceKey]map[string]string
+	pods               map[resourceKey]*clientV1.Pod
+	objects            map[resourceKey]metav1.Object
+	selectedConfigMaps map[string]string
+}
+
+// resourceKey identifies a cached resource; name is the pod selector for pods, and "kind/name" for objects.
+type resourceKey struct {
+	namespace string
+	name      string
+}
+
+func NewEvalContext() *EvalContext {
+	return &EvalContext{
+		secrets:            make(map[resourceKey]map[string]string),
+		configMaps:         make(map[resourceKey]map[string]string),
+		pods:               make(map[resourceKey]*clientV1.Pod),
+		objects:            make(map[resourceKey]metav1.Object),
+		selectedConfigMaps: make(map[string]string),
+	}
+}
+
+// NOTE: the following methods expect the mutex to be locked by the caller.
+
+func (c *EvalContext) secret(namespace, name string, optional bool) (map[string]string, error) {
+	key := resourceKey{namespace, name}
+	if data, found := c.secrets[key]; found {
+		return data, nil
+	}
+	data, err := fetchSecret(namespace, name, optional)
+	if err != nil || data == nil {
+		// a missing optional Secret is not cached, as a later non-optional access must fail
+		return data, err
+	}
+	c.secrets[key] = data
+	return data, nil
+}
+
+func (c *EvalContext) configMap(namespace, name string, optional bool) (map[string]string, error) {
+	key := resourceKey{namespace, name}
+	if data, found := c.configMaps[key]; found {
+		return data, nil
+	}
+	data, err := fetchConfigMap(namespace, name, optional)
+	if err != nil || data == nil {
+		return data, err
+	}
+	c.configMaps[key] = data
+	return data, nil
+}
+
+func (c *EvalContext) pod(namespace, podSelector string) (*clientV1.Pod, error) {
+	key := resourceKey{namespace, podSelector}
+	if pod, found := c.pods[key]; found {
+		return pod, nil
+	}
+	pod, err := podForSelector(namespace, podSelector)
+	if err != nil {
+		return nil, err
+	}
+	c.pods[key] = pod
+	return pod, nil
+}
+
+func (c *EvalContext) object(namespace, resource string) (metav1.Object, error) {
+	key := resourceKey{namespace, resource}
+	if object, found := c.objects[key]; found {
+		return object, nil
+	}
+	object, err := objectMeta(namespace, resource)
+	if err != nil {
+		return nil, err
+	}
+	c.objects[key] = object
+	return object, nil
+}
+
+// selectConfigMap returns the ConfigMap of the current namespace containing the variable; either given via
+// --configmap, the only one, or chosen by the user.
+func (c *EvalContext) selectConfigMap(variableNameToSearchFor string) (string, error) {
+	if selected, found := c.selectedConfigMaps[variableNameToSearchFor]; found {
+		return selected, nil
+	}
+	if len(Preselected.ConfigMap) > 0 {
+		return Preselected.ConfigMap, nil
+	}
+
+	namespace := CurrentNamespace()
+	configMaps, err := KubernetesClientset().CoreV1().ConfigMaps(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("%w (evaluating %s): %v", ErrConfigMapFetch, c.parameter, err)
+	}
+
+	configMapsIncludingVar := make([]clientV1.ConfigMap, 0)
+	for _, configMap := range configMaps.Items {
+		if len(configMap.Data[variableNameToSearchFor]) > 0 {
+			configMapsIncludingVar = append(configMapsIncludingVar, configMap)
+		}
+	}
+
+	if len(configMapsIncludingVar) == 0 {
+		return "", fmt.Errorf("no Config Map contains %s (evaluating %s)", variableNameToSearchFor, c.parameter)
+	}
+	if len(configMapsIncludingVar) == 1 {
+		c.selectedConfigMaps[variableNameToSearchFor] = configMapsIncludingVar[0].Name
+		return configMapsIncludingVar[0].Name, nil
+	}
+
+	fmt.Printf("Found multiple ConfigMaps containing Database Credentials:\n")
+	for ci, configMap := range configMapsIncludingVar {
+		fmt.Printf("%d: %v\n", ci, aurora.Green(configMap.Name))
+	}
+	ci, err := utility.GetNumberChoice("pass --configmap")
+	if err != nil {
+		return "", PromptError(err)
+	}
+	if ci < 0 || ci >= len(configMapsIncludingVar) {
+		return "", fmt.Errorf("%w: Config Map %d does not exist", ErrPromptAborted, ci)
+	}
+
+	c.selectedConfigMaps[variableNameToSearchFor] = configMapsIncludingVar[ci].Name
+	return configMapsIncludingVar[ci].Name, nil
+}
@@ -0,0 +1,122 @@
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/crypto/ssh/terminal"
+	clientV1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// Exec runs the command in a container of the pod (like kubectl exec -i), connected to stdin, stdout and stderr.
+// If stdin is a terminal, a TTY is allocated: the local terminal is switched to raw mode, and size changes are
+// propagated. A non-zero exit code of the command is returned as RemoteExitError.
+func Exec(namespace, podName, containerName string, command []string) error {
+	stdinFd := int(os.Stdin.Fd())
+	tty := terminal.IsTerminal(stdinFd)
+
+	request := KubernetesClientset().CoreV1().RESTClient().Post().
+		Namespace(namespace).
+		Resource("pods").
+		Name(podName).
+		SubResource("exec").
+		VersionedParams(&clientV1.PodExecOptions{
+			Container: containerName,
+			Command:   command,
+			Stdin:     true,
+			Stdout:    true,
+			// with a TTY, stderr is sent through stdout
+			Stderr: !tty,
+			TTY:    tty,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(KubernetesRestConfig(), "POST", request.URL())
+	if err != nil {
+		return fmt.Errorf("could not connect to pod %s: %w", podName, err)
+	}
+
+	streamOptions := remotecommand.StreamOptions{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		Tty:    tty,
+	}
+	if tty {
+		streamOptions.Stderr = nil
+		previousState, err := terminal.MakeRaw(stdinFd)
+		if err != nil {
+			return fmt.Errorf("could not switch the terminal to raw mode: %w", err)
+		}
+		defer terminal.Restore(stdinFd, previousState)
+
+		sizeQueue := newTerminalSizeQueue(int(os.Stdout.Fd()))
+		defer sizeQueue.stop()
+		streamOptions.TerminalSizeQueue = sizeQueue
+	}
+
+	err = executor.Stream(streamOptions)
+	var exitError utilexec.ExitError
+	if errors.As(err, &exitError) {
+		return RemoteExitError{Pod: podName, Code: exitError.ExitStatus()}
+	}
+	if err != nil {
+		return fmt.Errorf("exec in pod %s failed: %w", podName, err)
+	}
+	return nil
+}
+
+// terminalSizeQueue reports the size of the local terminal: initially, and after each SIGWINCH.
+type terminalSizeQueue struct {
+	fd      int
+	sizes   chan remotecommand.TerminalSize
+	signals chan os.Signal
+}
+
+func newTerminalSizeQueue(fd int) *terminalSizeQueue {
+	queue := &terminalSizeQueue{
+		fd:      fd,
+		sizes:   make(chan remotecommand.TerminalSize, 1),
+		signals: make(chan os.Signal, 1),
+	}
+	queue.push()
+	signal.Notify(queue.signals, syscall.SIGWINCH)
+	go func() {
+		for range queue.signals {
+			queue.push()
+		}
+		close(queue.sizes)
+	}()
+	return queue
+}
+
+// push replaces a size not yet consumed by the current one.
+func (q *terminalSizeQueue) push() {
+	width, height, err := terminal.GetSize(q.fd)
+	if err != nil {
+		return
+	}
+	select {
+	case <-q.sizes:
+	default:
+	}
+	q.sizes <- remotecommand.TerminalSize{Width: uint16(width), Height: uint16(height)}
+}
+
+// Next implements remotecommand.TerminalSizeQueue.
+func (q *terminalSizeQueue) Next() *remotecommand.TerminalSize {
+	size, ok := <-q.sizes
+	if !ok {
+		return nil
+	}
+	return &size
+}
+
+func (q *terminalSizeQueue) stop() {
+	signal.Stop(q.signals)
+	close(q.signals)
+}
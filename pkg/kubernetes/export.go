@@ -0,0 +1,102 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	clientV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// WorkloadObject fetches the workload given as "Kind/name" (as returned by PodOwners), including apiVersion and kind.
+func WorkloadObject(namespace, workload string) (runtime.Object, error) {
+	parts := strings.SplitN(workload, "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("workload %s must be given as Kind/name", workload)
+	}
+	kind, name := parts[0], parts[1]
+
+	ctx := context.Background()
+	options := metav1.GetOptions{}
+	var object runtime.Object
+	var err error
+	switch kind {
+	case "Deployment":
+		object, err = KubernetesClientset().AppsV1().Deployments(namespace).Get(ctx, name, options)
+	case "StatefulSet":
+		object, err = KubernetesClientset().AppsV1().StatefulSets(namespace).Get(ctx, name, options)
+	case "DaemonSet":
+		object, err = KubernetesClientset().AppsV1().DaemonSets(namespace).Get(ctx, name, options)
+	case "ReplicaSet":
+		object, err = KubernetesClientset().AppsV1().ReplicaSets(namespace).Get(ctx, name, options)
+	case "Job":
+		object, err = KubernetesClientset().BatchV1().Jobs(namespace).Get(ctx, name, options)
+	case "CronJob":
+		object, err = KubernetesClientset().BatchV1beta1().CronJobs(namespace).Get(ctx, name, options)
+	default:
+		return nil, fmt.Errorf("workloads of kind %s are not supported", kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s could not be fetched: %w", workload, err)
+	}
+	return withTypeMeta(object)
+}
+
+// withTypeMeta sets apiVersion and kind, which are empty in objects returned by the clientset.
+func withTypeMeta(object runtime.Object) (runtime.Object, error) {
+	kinds, _, err := scheme.Scheme.ObjectKinds(object)
+	if err != nil || len(kinds) == 0 {
+		return nil, fmt.Errorf("the kind of %T is unknown: %v", object, err)
+	}
+	object.GetObjectKind().SetGroupVersionKind(kinds[0])
+	return object, nil
+}
+
+// PodObject returns the pod including apiVersion and kind, without managedFields (which only add noise).
+func PodObject(pod *clientV1.Pod) (runtime.Object, error) {
+	pod = pod.DeepCopy()
+	pod.ManagedFields = nil
+	return withTypeMeta(pod)
+}
+
+// ContainerLog reads the log of a container; with previous, of its previous instance.
+func ContainerLog(namespace, podName, containerName string, previous bool, since time.Time) ([]byte, error) {
+	options := &clientV1.PodLogOptions{
+		Container:  containerName,
+		Previous:   previous,
+		Timestamps: true,
+	}
+	if !since.IsZero() {
+		options.SinceTime = &metav1.Time{Time: since}
+	}
+	return KubernetesClientset().CoreV1().Pods(namespace).GetLogs(podName, options).DoRaw(context.Background())
+}
+
+// NamespaceEvents returns the events of the namespace (which Kubernetes keeps for about an hour), oldest first.
+func NamespaceEvents(namespace string) ([]clientV1.Event, error) {
+	events, err := KubernetesClientset().CoreV1().Events(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("events could not be listed: %w", err)
+	}
+	sort.SliceStable(events.Items, func(i, j int) bool {
+		return EventTime(&events.Items[i]).Before(EventTime(&events.Items[j]))
+	})
+	return events.Items, nil
+}
+
+// EventTime returns when the event was last seen.
+func EventTime(event *clientV1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
@@ -18,10 +18,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/dop251/goja"
 	"github.com/logrusorgru/aurora"
-	"github.com/manifoldco/promptui"
 	"github.com/sandstorm/sku/pkg/utility"
 	clientV1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -29,9 +28,8 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
-	"log"
-	"os"
 	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -39,26 +37,56 @@ import (
 var clientset *kubernetes.Clientset
 var config *rest.Config
 var apiConfig *clientcmdapi.Config
+var currentContextName string
+var currentNamespace string
 
-func KubernetesInit() {
+// KubernetesInit loads the kubeconfig and creates the client. contextOverride and namespaceOverride (if not empty)
+// take precedence over the current context and its namespace - only for this process; the kubeconfig is not modified.
+func KubernetesInit(contextOverride, namespaceOverride string) error {
 
 	loader := clientcmd.NewDefaultClientConfigLoadingRules()
 	var err error
 	apiConfig, err = loader.Load()
+	if err != nil {
+		return fmt.Errorf("could not load the kubeconfig: %w", err)
+	}
+	if len(contextOverride) > 0 {
+		if _, exists := apiConfig.Contexts[contextOverride]; !exists {
+			return fmt.Errorf("%w: %s", ErrContextNotFound, contextOverride)
+		}
+	}
 
-	config, err = clientcmd.BuildConfigFromKubeconfigGetter("", loader.Load)
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: contextOverride,
+		Context: clientcmdapi.Context{
+			Namespace: namespaceOverride,
+		},
+	}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loader, overrides)
 
+	config, err = clientConfig.ClientConfig()
+	if err != nil {
+		return fmt.Errorf("could not build the client config: %w", err)
+	}
+	currentNamespace, _, err = clientConfig.Namespace()
 	if err != nil {
-		panic(err.Error())
+		return fmt.Errorf("could not determine the namespace: %w", err)
+	}
+	currentContextName = apiConfig.CurrentContext
+	if len(contextOverride) > 0 {
+		currentContextName = contextOverride
 	}
 
 	// create the clientset
 	clientset, err = kubernetes.NewForConfig(config)
 	if err != nil {
-		panic(err.Error())
+		return fmt.Errorf("could not create the Kubernetes client: %w", err)
 	}
+	return nil
 }
 
+// KubernetesApiConfig returns the kubeconfig as stored on disk; i.e. without --context and --namespace applied.
+// Use CurrentContextName and CurrentNamespace to find out where sku operates.
 func KubernetesApiConfig() *clientcmdapi.Config {
 	return apiConfig
 }
@@ -67,6 +95,20 @@ func KubernetesClientset() *kubernetes.Clientset {
 	return clientset
 }
 
+func KubernetesRestConfig() *rest.Config {
+	return config
+}
+
+// CurrentContextName is the context sku operates on; --context or the current context of the kubeconfig.
+func CurrentContextName() string {
+	return currentContextName
+}
+
+// CurrentNamespace is the namespace sku operates in; --namespace or the namespace of the current context.
+func CurrentNamespace() string {
+	return currentNamespace
+}
+
 type VersionResponse struct {
 	ClientVersion ClientVersionResponse `json:"clientVersion"`
 }
@@ -76,13 +118,13 @@ type ClientVersionResponse struct {
 	Minor string `json:"minor"`
 }
 
-func EnsureVersionOfKubernetesCliSupportsExternalAuth() {
+func EnsureVersionOfKubernetesCliSupportsExternalAuth() error {
 	cmd := exec.Command("kubectl", "version", "--client", "--output=json")
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	err := cmd.Run()
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("could not determine the kubectl version: %w", err)
 	}
 
 	response := &VersionResponse{}
@@ -93,54 +135,57 @@ func EnsureVersionOfKubernetesCliSupportsExternalAuth() {
 
 	supportsExternalAuth := (major == 1 && minor >= 11) || major >= 2
 	if !supportsExternalAuth {
-		log.Printf("ERROR: kubectl must be at least version 1.11 to support External Auth.\n")
-		log.Printf("Found version was: %d.%d.\n", major, minor)
-		log.Printf("To fix this issue, run 'brew install kubernetes-cli' or 'brew upgrade kubernetes-cli'\n")
-		log.Fatalf("ABORTING!\n")
+		return fmt.Errorf("kubectl must be at least version 1.11 to support External Auth, found version was: %d.%d. To fix this issue, run 'brew install kubernetes-cli' or 'brew upgrade kubernetes-cli'", major, minor)
 	}
+	return nil
 }
 
-func EnsureContextExists(newContext string) {
-	foundContext := false
+// EnsureContextExists returns an error wrapping ErrContextNotFound if there is no such context in the kubeconfig.
+func EnsureContextExists(newContext string) error {
 	for context := range KubernetesApiConfig().Contexts {
 		if context == newContext {
-			foundContext = true
+			return nil
 		}
 	}
 
-	if !foundContext {
-		fmt.Printf("%v\n", aurora.Red("Context not found; use one of the list below:"))
-		PrintExistingContexts()
-		os.Exit(1)
-	}
+	return fmt.Errorf("%w: %s", ErrContextNotFound, newContext)
+}
 
+// ContextNames returns the names of all contexts in the kubeconfig, sorted.
+func ContextNames() []string {
+	contextNames := make([]string, 0, len(KubernetesApiConfig().Contexts))
+	for context := range KubernetesApiConfig().Contexts {
+		contextNames = append(contextNames, context)
+	}
+	sort.Strings(contextNames)
+	return contextNames
 }
 
 func PrintExistingContexts() {
-	currentContext := KubernetesApiConfig().CurrentContext
+	currentContext := CurrentContextName()
 	for context := range KubernetesApiConfig().Contexts {
+		protectionHint := ""
+		if extension, _ := contextExtension(context); extension.Protected || len(extension.ProtectedNamespaces) > 0 || isProtectedBySettings(context) {
+			protectionHint = aurora.Red(" (protected)").String()
+		}
 		if context == currentContext {
-			fmt.Printf("* %v\n", aurora.Green(context))
+			fmt.Printf("* %v%s\n", aurora.Green(context), protectionHint)
 		} else {
-			fmt.Printf("  %v\n", context)
+			fmt.Printf("  %v%s\n", context, protectionHint)
 		}
 	}
 
 }
 
-func EnsureNamespaceExists(namespace string, namespaceList *clientV1.NamespaceList) {
-	foundNamespace := false
+// EnsureNamespaceExists returns an error wrapping ErrNamespaceNotFound if the namespace is not in the list.
+func EnsureNamespaceExists(namespace string, namespaceList *clientV1.NamespaceList) error {
 	for _, ns := range namespaceList.Items {
 		if namespace == ns.Name {
-			foundNamespace = true
+			return nil
 		}
 	}
 
-	if !foundNamespace {
-		fmt.Printf("%v\n", aurora.Red("Namespace not found; use one of the list below:"))
-		PrintExistingNamespaces(namespaceList)
-		os.Exit(1)
-	}
+	return fmt.Errorf("%w: %s", ErrNamespaceNotFound, namespace)
 }
 
 func NamespacesToString(namespaceList *clientV1.NamespaceList) []string {
@@ -152,11 +197,8 @@ func NamespacesToString(namespaceList *clientV1.NamespaceList) []string {
 }
 
 func PrintExistingNamespaces(namespaceList *clientV1.NamespaceList) {
-	currentContext := KubernetesApiConfig().CurrentContext
-	context := KubernetesApiConfig().Contexts[currentContext]
-
 	for _, ns := range namespaceList.Items {
-		if context.Namespace == ns.Name {
+		if CurrentNamespace() == ns.Name {
 			fmt.Printf("* %v\n", aurora.Green(ns.Name))
 		} else {
 			fmt.Printf("  %v\n", ns.Name)
@@ -165,12 +207,33 @@ func PrintExistingNamespaces(namespaceList *clientV1.NamespaceList) {
 	}
 }
 
-func SelectPod(promptLabel string) string {
-	currentContext := KubernetesApiConfig().CurrentContext
-	k8sContextDefinition := KubernetesApiConfig().Contexts[currentContext]
+// Preselection holds answers to the interactive selections, given via global flags; so that sku can
+// run from scripts (together with --non-interactive).
+type Preselection struct {
+	// Pod is a pod name or a label selector
+	Pod       string
+	Container string
+	ConfigMap string
+	// Workload is "Kind/name" or just the name of a Deployment or StatefulSet
+	Workload string
+}
+
+var Preselected = Preselection{}
+
+// SelectPod returns the name of a running pod in the current namespace; either given via --pod, the only
+// running one, or chosen by the user.
+func SelectPod(promptLabel string) (string, error) {
+	namespace := CurrentNamespace()
+
+	if len(Preselected.Pod) > 0 {
+		return resolvePreselectedPod(namespace)
+	}
 
 	// query for running pods in current namespace
-	podList, _ := KubernetesClientset().CoreV1().Pods(k8sContextDefinition.Namespace).List(context.Background(), metav1.ListOptions{})
+	podList, err := KubernetesClientset().CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("pods could not be listed: %w", err)
+	}
 
 	podNames := make([]string, 0, len(podList.Items))
 	for _, pod := range podList.Items {
@@ -179,111 +242,238 @@ func SelectPod(promptLabel string) string {
 		}
 	}
 
+	if len(podNames) == 0 {
+		return "", fmt.Errorf("%w in namespace %s", ErrPodNotFound, namespace)
+	}
 	if len(podNames) == 1 {
 		fmt.Printf("%s found exactly one pod, using this one: %s\n", aurora.Yellow("INFO:"), podNames[0])
-		return podNames[0]
+		return podNames[0], nil
 	}
 
-	prompt := promptui.Select{
-		Label: aurora.Bold(promptLabel),
-		Items: podNames,
+	i, err := utility.Select(promptLabel, podNames, "pass --pod with a pod name or label selector")
+	if err != nil {
+		return "", PromptError(err)
 	}
 
-	_, result, err := prompt.Run()
+	return podNames[i], nil
+}
+
+// PromptError wraps errors of a prompt into ErrPromptAborted; except for utility.ErrInteractionRequired.
+func PromptError(err error) error {
+	if errors.Is(err, utility.ErrInteractionRequired) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrPromptAborted, err)
+}
 
+// resolvePreselectedPod resolves --pod, which is either a pod name or a label selector.
+func resolvePreselectedPod(namespace string) (string, error) {
+	pod, err := findRunningPod(namespace, Preselected.Pod)
 	if err != nil {
-		fmt.Printf("%s prompt failed:\n    %v\n", aurora.Red("ERROR:"), err)
-		// TODO: get rid of os.Exit here (breaks the outer goroutines)
-		os.Exit(1)
+		return "", fmt.Errorf("%w (given via --pod)", err)
+	}
+	if pod.Name != Preselected.Pod {
+		fmt.Printf("%s using pod %s matching %s\n", aurora.Yellow("INFO:"), pod.Name, Preselected.Pod)
+	}
+	return pod.Name, nil
+}
+
+// findRunningPod returns the running pod with the given name; or, for a label selector, the first running pod
+// (they are usually replicas of the same workload).
+func findRunningPod(namespace, podNameOrSelector string) (*clientV1.Pod, error) {
+	if !strings.ContainsAny(podNameOrSelector, "=,!") {
+		pod, err := KubernetesClientset().CoreV1().Pods(namespace).Get(context.Background(), podNameOrSelector, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("%w: pod %s: %v", ErrPodNotFound, podNameOrSelector, err)
+		}
+		if pod.Status.Phase != clientV1.PodRunning {
+			return nil, fmt.Errorf("%w: pod %s is %s", ErrPodNotFound, pod.Name, pod.Status.Phase)
+		}
+		return pod, nil
 	}
 
-	return result
+	podList, err := KubernetesClientset().CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: podNameOrSelector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pods for label selector %s could not be listed: %w", podNameOrSelector, err)
+	}
+	for i := range podList.Items {
+		if podList.Items[i].Status.Phase == clientV1.PodRunning {
+			return &podList.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w for the label selector %s", ErrPodNotFound, podNameOrSelector)
 }
 
-var selectedConfigmap = ""
-
-func EvalScriptParameter(parameter string) string {
-	if strings.HasPrefix(parameter, "eval:") {
-		vm := goja.New()
-		vm.Set("secret", func(secretName string) map[string]string {
-			currentContext := KubernetesApiConfig().CurrentContext
-			k8sContextDefinition := KubernetesApiConfig().Contexts[currentContext]
-			secret, err := KubernetesClientset().CoreV1().Secrets(k8sContextDefinition.Namespace).Get(context.Background(), secretName, metav1.GetOptions{})
-			if err != nil {
-				fmt.Printf("%s Secret %s could not be fetched (evaluating %s):\n    %s\n", aurora.Red("ERROR:"), aurora.Bold(secretName), aurora.Bold(parameter), err)
-				// TODO: get rid of os.Exit here (breaks the outer goroutines)
-				os.Exit(1)
+// SelectContainer returns the container name of the pod to use; the empty string if the pod has only
+// a single container.
+func SelectContainer(pod *clientV1.Pod) (string, error) {
+	if len(Preselected.Container) > 0 {
+		for _, c := range pod.Spec.Containers {
+			if c.Name == Preselected.Container {
+				return c.Name, nil
 			}
+		}
+		return "", fmt.Errorf("container %s given via --container not found in pod %s", Preselected.Container, pod.Name)
+	}
 
-			converted := make(map[string]string, len(secret.Data))
-			for k, v := range secret.Data {
-				converted[k] = string(v)
-			}
-			return converted
-		})
+	if len(pod.Spec.Containers) <= 1 {
+		return "", nil
+	}
 
-		vm.Set("selectInteractively", func(variableNameToSearchFor string) string {
-			if len(selectedConfigmap) > 0 {
-				return selectedConfigmap
-			}
+	fmt.Printf("Which container?.\n")
+	for ci, c := range pod.Spec.Containers {
+		fmt.Printf("%d: %v\n", ci, aurora.Green(c.Name))
+	}
+	ci, err := utility.GetNumberChoice("pass --container")
+	if err != nil {
+		return "", PromptError(err)
+	}
+	if ci < 0 || ci >= len(pod.Spec.Containers) {
+		return "", fmt.Errorf("container %d does not exist", ci)
+	}
 
-			currentContext := KubernetesApiConfig().CurrentContext
-			k8sContextDefinition := KubernetesApiConfig().Contexts[currentContext]
-			configMaps, err := KubernetesClientset().CoreV1().ConfigMaps(k8sContextDefinition.Namespace).List(context.Background(), metav1.ListOptions{})
+	return pod.Spec.Containers[ci].Name, nil
+}
 
-			if err != nil {
-				fmt.Printf("%s Config Maps could not be fetched (evaluating %s):\n    %s\n", aurora.Red("ERROR:"), aurora.Bold(parameter), err)
-				os.Exit(1)
-			}
-			configMapsIncludingVar := make([]clientV1.ConfigMap, 0)
-			for _, configMap := range configMaps.Items {
-				if len(configMap.Data[variableNameToSearchFor]) > 0 {
-					configMapsIncludingVar = append(configMapsIncludingVar, configMap)
-				}
-			}
+// Workload is a Deployment or StatefulSet, i.e. something which creates pods.
+type Workload struct {
+	Kind        string
+	Name        string
+	Selector    *metav1.LabelSelector
+	PodTemplate clientV1.PodTemplateSpec
+}
 
-			fmt.Printf("Found multiple ConfigMaps containing Database Credentials:\n")
-			for ci, c := range configMapsIncludingVar {
-				fmt.Printf("%d: %v\n", ci, aurora.Green(c.Name))
-			}
-			ci := utility.GetNumberChoice()
+func (w Workload) String() string {
+	return w.Kind + "/" + w.Name
+}
 
-			selectedConfigmap = configMapsIncludingVar[ci].Name
+// ListWorkloads returns all Deployments and StatefulSets of the given namespace.
+func ListWorkloads(namespace string) ([]Workload, error) {
+	workloads := make([]Workload, 0)
 
-			return selectedConfigmap
+	deployments, err := KubernetesClientset().AppsV1().Deployments(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, deployment := range deployments.Items {
+		workloads = append(workloads, Workload{
+			Kind:        "Deployment",
+			Name:        deployment.Name,
+			Selector:    deployment.Spec.Selector,
+			PodTemplate: deployment.Spec.Template,
+		})
+	}
 
+	statefulSets, err := KubernetesClientset().AppsV1().StatefulSets(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, statefulSet := range statefulSets.Items {
+		workloads = append(workloads, Workload{
+			Kind:        "StatefulSet",
+			Name:        statefulSet.Name,
+			Selector:    statefulSet.Spec.Selector,
+			PodTemplate: statefulSet.Spec.Template,
 		})
+	}
 
-		vm.Set("configmap", func(configmapName string) map[string]string {
-			currentContext := KubernetesApiConfig().CurrentContext
-			k8sContextDefinition := KubernetesApiConfig().Contexts[currentContext]
-			configMap, err := KubernetesClientset().CoreV1().ConfigMaps(k8sContextDefinition.Namespace).Get(context.Background(), configmapName, metav1.GetOptions{})
+	return workloads, nil
+}
+
+// PodOwners returns the workload owning each pod (by pod name) as "Kind/name"; pods of a ReplicaSet are attributed to
+// its Deployment, and pods of a Job to its CronJob. Pods without owner get the empty string. If ReplicaSets or Jobs
+// cannot be listed (e.g. missing permissions), the ReplicaSet or Job itself is used.
+func PodOwners(namespace string, pods []clientV1.Pod) map[string]string {
+	// controller of each ReplicaSet / Job, fetched on demand
+	var replicaSetOwners, jobOwners map[string]*metav1.OwnerReference
+
+	owners := make(map[string]string, len(pods))
+	for i := range pods {
+		controller := metav1.GetControllerOf(&pods[i])
+		if controller == nil {
+			owners[pods[i].Name] = ""
+			continue
+		}
 
-			if err != nil {
-				fmt.Printf("%s Config Map %s could not be fetched (evaluating %s):\n    %s\n", aurora.Red("ERROR:"), aurora.Bold(configmapName), aurora.Bold(parameter), err)
-				// TODO: get rid of os.Exit here (breaks the outer goroutines)
-				os.Exit(1)
+		var parent *metav1.OwnerReference
+		switch controller.Kind {
+		case "ReplicaSet":
+			if replicaSetOwners == nil {
+				replicaSetOwners = make(map[string]*metav1.OwnerReference)
+				if replicaSets, err := KubernetesClientset().AppsV1().ReplicaSets(namespace).List(context.Background(), metav1.ListOptions{}); err == nil {
+					for j := range replicaSets.Items {
+						replicaSetOwners[replicaSets.Items[j].Name] = metav1.GetControllerOf(&replicaSets.Items[j])
+					}
+				}
+			}
+			parent = replicaSetOwners[controller.Name]
+		case "Job":
+			if jobOwners == nil {
+				jobOwners = make(map[string]*metav1.OwnerReference)
+				if jobs, err := KubernetesClientset().BatchV1().Jobs(namespace).List(context.Background(), metav1.ListOptions{}); err == nil {
+					for j := range jobs.Items {
+						jobOwners[jobs.Items[j].Name] = metav1.GetControllerOf(&jobs.Items[j])
+					}
+				}
 			}
+			parent = jobOwners[controller.Name]
+		}
 
-			return configMap.Data
-		})
+		if parent != nil {
+			owners[pods[i].Name] = parent.Kind + "/" + parent.Name
+		} else {
+			owners[pods[i].Name] = controller.Kind + "/" + controller.Name
+		}
+	}
+	return owners
+}
 
-		v, err := vm.RunString(parameter[5:])
-		if err != nil {
-			fmt.Printf("%s Could not evaluate %s:\n    %s\n", aurora.Red("ERROR:"), aurora.Bold(parameter), err)
-			// TODO: get rid of os.Exit here (breaks the outer goroutines)
-			os.Exit(1)
+// IsPodReady returns true if the pod is running and all its containers are ready.
+func IsPodReady(pod *clientV1.Pod) bool {
+	if pod.Status.Phase != clientV1.PodRunning {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == clientV1.PodReady {
+			return condition.Status == clientV1.ConditionTrue
 		}
+	}
+	return false
+}
+
+// SelectWorkload returns a Deployment or StatefulSet of the current namespace; either given via --workload,
+// the only one, or chosen by the user.
+func SelectWorkload(promptLabel string) (Workload, error) {
+	namespace := CurrentNamespace()
+
+	workloads, err := ListWorkloads(namespace)
+	if err != nil {
+		return Workload{}, fmt.Errorf("workloads could not be fetched: %w", err)
+	}
+	if len(workloads) == 0 {
+		return Workload{}, fmt.Errorf("no Deployments or StatefulSets found in namespace %s", namespace)
+	}
 
-		converted, isOk := v.Export().(string)
-		if !isOk {
-			fmt.Printf("%s Could not convert %s:\n    Value: %+v\n", aurora.Red("ERROR:"), aurora.Bold(parameter), v)
-			// TODO: get rid of os.Exit here (breaks the outer goroutines)
-			os.Exit(1)
+	if len(Preselected.Workload) > 0 {
+		for _, workload := range workloads {
+			if workload.String() == Preselected.Workload || workload.Name == Preselected.Workload {
+				return workload, nil
+			}
 		}
-		return converted
-	} else {
-		return parameter
+		return Workload{}, fmt.Errorf("workload %s given via --workload not found", Preselected.Workload)
+	}
+
+	if len(workloads) == 1 {
+		fmt.Printf("%s found exactly one workload, using this one: %s\n", aurora.Yellow("INFO:"), workloads[0])
+		return workloads[0], nil
+	}
+
+	i, err := utility.Select(promptLabel, workloads, "pass --workload")
+	if err != nil {
+		return Workload{}, PromptError(err)
 	}
 
+	return workloads[i], nil
 }
@@ -0,0 +1,201 @@
+package kubernetes
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	clientV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// LogLine is a single line of a container log.
+type LogLine struct {
+	Pod       string
+	Container string
+	// Time is when the line was logged, as recorded by the container runtime.
+	Time time.Time
+	Text string
+}
+
+// LogStreamOptions selects what StreamLogs shows.
+type LogStreamOptions struct {
+	LabelSelector string
+	// Pod restricts the logs to the pod with this name; empty for all pods matching the label selector.
+	Pod string
+	// Container restricts the logs to the container with this name; empty for all containers.
+	Container string
+	// Follow keeps streaming, and picks up new pods and restarted containers.
+	Follow bool
+	// Previous shows the logs of the previous instance of restarted containers; implies not following.
+	Previous bool
+	// Since and Until limit the logs to a time window; the zero time does not limit.
+	Since time.Time
+	Until time.Time
+	// TailLines limits the logs of the containers running when starting to their last lines; negative for all.
+	TailLines int64
+}
+
+// logSource is a container instance; a restarted container is a new source.
+type logSource struct {
+	pod          string
+	container    string
+	restartCount int32
+}
+
+// StreamLogs reads the logs of all containers of the pods matching the options concurrently, and calls
+// handleLine for each line (never concurrently). When following, pods which are started and containers
+// which are restarted meanwhile are picked up, and it only returns on errors.
+func StreamLogs(namespace string, options LogStreamOptions, handleLine func(line LogLine)) error {
+	pods := KubernetesClientset().CoreV1().Pods(namespace)
+	listOptions := metav1.ListOptions{LabelSelector: options.LabelSelector}
+	if len(options.Pod) > 0 {
+		listOptions.FieldSelector = fields.OneTermEqualSelector("metadata.name", options.Pod).String()
+	}
+	follow := options.Follow && !options.Previous
+
+	var lock sync.Mutex
+	var running sync.WaitGroup
+	active := make(map[logSource]bool)
+	emit := func(line LogLine) {
+		lock.Lock()
+		defer lock.Unlock()
+		handleLine(line)
+	}
+
+	read := func(pod *clientV1.Pod, initial bool) {
+		for _, status := range pod.Status.ContainerStatuses {
+			if len(options.Container) > 0 && status.Name != options.Container {
+				continue
+			}
+			if options.Previous && status.RestartCount == 0 {
+				continue
+			}
+			if !options.Previous && status.State.Running == nil && status.State.Terminated == nil {
+				// waiting containers have no log (yet)
+				continue
+			}
+			source := logSource{pod: pod.Name, container: status.Name, restartCount: status.RestartCount}
+			lock.Lock()
+			if active[source] {
+				lock.Unlock()
+				continue
+			}
+			active[source] = true
+			lock.Unlock()
+
+			podLogOptions := &clientV1.PodLogOptions{
+				Container:  source.container,
+				Follow:     follow,
+				Previous:   options.Previous,
+				Timestamps: true,
+			}
+			if !options.Since.IsZero() {
+				podLogOptions.SinceTime = &metav1.Time{Time: options.Since}
+			}
+			// containers started while following are shown completely
+			if initial && options.TailLines >= 0 {
+				tailLines := options.TailLines
+				podLogOptions.TailLines = &tailLines
+			}
+
+			running.Add(1)
+			go func() {
+				defer running.Done()
+				// sources stay active, so the log of a terminated container is not shown again
+				err := readContainerLog(namespace, source, podLogOptions, options.Until, emit)
+				if err != nil {
+					emit(LogLine{Pod: source.pod, Container: source.container, Time: time.Now(), Text: fmt.Sprintf("(log stream failed: %v)", err)})
+				}
+			}()
+		}
+	}
+
+	podList, err := pods.List(context.Background(), listOptions)
+	if err != nil {
+		return fmt.Errorf("pods could not be listed: %w", err)
+	}
+	if len(options.Pod) > 0 && len(podList.Items) == 0 {
+		return fmt.Errorf("%w: %s", ErrPodNotFound, options.Pod)
+	}
+	for i := range podList.Items {
+		read(&podList.Items[i], true)
+	}
+	if !follow {
+		running.Wait()
+		return nil
+	}
+
+	// watches end after a server-side timeout; then, the pods are listed again
+	for {
+		watchOptions := listOptions
+		watchOptions.ResourceVersion = podList.ResourceVersion
+		watcher, err := pods.Watch(context.Background(), watchOptions)
+		if err != nil {
+			return fmt.Errorf("pods could not be watched: %w", err)
+		}
+		for event := range watcher.ResultChan() {
+			if event.Type != watch.Added && event.Type != watch.Modified {
+				continue
+			}
+			if pod, ok := event.Object.(*clientV1.Pod); ok {
+				read(pod, false)
+			}
+		}
+
+		podList, err = pods.List(context.Background(), listOptions)
+		if err != nil {
+			return fmt.Errorf("pods could not be listed: %w", err)
+		}
+		for i := range podList.Items {
+			read(&podList.Items[i], false)
+		}
+	}
+}
+
+// readContainerLog reads the log of the container instance until its end; when following, until the container
+// has terminated. Lines after until are skipped, unless until is the zero time.
+func readContainerLog(namespace string, source logSource, podLogOptions *clientV1.PodLogOptions, until time.Time, emit func(line LogLine)) error {
+	stream, err := KubernetesClientset().CoreV1().Pods(namespace).GetLogs(source.pod, podLogOptions).Stream(context.Background())
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	reader := bufio.NewReader(stream)
+	for {
+		text, err := reader.ReadString('\n')
+		if len(text) > 0 {
+			line := parseLogLine(source, strings.TrimSuffix(text, "\n"))
+			if !until.IsZero() && line.Time.After(until) {
+				// the lines are in order, so all following lines are later as well
+				return nil
+			}
+			emit(line)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parseLogLine splits the timestamp (added because of PodLogOptions.Timestamps) from the line.
+func parseLogLine(source logSource, text string) LogLine {
+	line := LogLine{Pod: source.pod, Container: source.container, Text: text}
+	if separator := strings.IndexByte(text, ' '); separator > 0 {
+		if timestamp, err := time.Parse(time.RFC3339Nano, text[:separator]); err == nil {
+			line.Time = timestamp
+			line.Text = text[separator+1:]
+		}
+	}
+	return line
+}
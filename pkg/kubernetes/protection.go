@@ -0,0 +1,138 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/manifoldco/promptui"
+	skuConfig "github.com/sandstorm/sku/pkg/config"
+	"github.com/sandstorm/sku/pkg/utility"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ProtectionExtensionName is the name of the sku extension in a context of the kubeconfig:
+//
+//	contexts:
+//	- name: production
+//	  context:
+//	    ...
+//	    extensions:
+//	    - name: sku.sandstorm.de
+//	      extension:
+//	        protected: true
+//	        # optional: only protect these namespaces, instead of the whole context
+//	        protectedNamespaces: [shop]
+const ProtectionExtensionName = "sku.sandstorm.de"
+
+// ContextExtension is the content of the sku extension of a kubeconfig context.
+type ContextExtension struct {
+	Protected           bool     `json:"protected"`
+	ProtectedNamespaces []string `json:"protectedNamespaces"`
+}
+
+// AllowProtected is set by --allow-protected: destructive operations in protected contexts are allowed in
+// non-interactive mode (together with --yes); interactively, it has no effect.
+var AllowProtected = false
+
+// contextExtension reads the sku extension of the given context; a missing extension is not an error.
+func contextExtension(contextName string) (ContextExtension, error) {
+	contextExtension := ContextExtension{}
+	context, exists := KubernetesApiConfig().Contexts[contextName]
+	if !exists {
+		return contextExtension, nil
+	}
+	extension, exists := context.Extensions[ProtectionExtensionName]
+	if !exists {
+		return contextExtension, nil
+	}
+	unknown, isUnknown := extension.(*runtime.Unknown)
+	if !isUnknown {
+		return contextExtension, fmt.Errorf("extension %s of context %s has an unexpected type %T", ProtectionExtensionName, contextName, extension)
+	}
+	if err := json.Unmarshal(unknown.Raw, &contextExtension); err != nil {
+		return contextExtension, fmt.Errorf("extension %s of context %s could not be parsed: %w", ProtectionExtensionName, contextName, err)
+	}
+	return contextExtension, nil
+}
+
+// IsProtected returns true if destructive operations in the given context and namespace need extra confirmation;
+// configured via the kubeconfig extension or the "protected" setting in the sku config.
+func IsProtected(contextName, namespace string) (bool, error) {
+	if protected, _ := skuConfig.Lookup(contextName, namespace, skuConfig.KeyProtected); protected == "true" {
+		return true, nil
+	}
+
+	extension, err := contextExtension(contextName)
+	if err != nil {
+		return false, err
+	}
+	if len(extension.ProtectedNamespaces) > 0 {
+		for _, protectedNamespace := range extension.ProtectedNamespaces {
+			if protectedNamespace == namespace {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return extension.Protected, nil
+}
+
+// isProtectedBySettings returns true if the context or one of its namespaces is protected via the sku config.
+func isProtectedBySettings(contextName string) bool {
+	contextScope := skuConfig.Current().Contexts[contextName]
+	if contextScope == nil {
+		return false
+	}
+	if contextScope.Settings[skuConfig.KeyProtected] == "true" {
+		return true
+	}
+	for _, namespaceScope := range contextScope.Namespaces {
+		if namespaceScope != nil && namespaceScope.Settings[skuConfig.KeyProtected] == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfirmDestructive asks for confirmation of a destructive operation in the current context and namespace.
+//
+// In unprotected contexts, this is a normal utility.Confirm. In protected contexts, a banner is shown and the user
+// needs to type the namespace name, even with --yes and --allow-protected; in non-interactive mode, this fails with
+// ErrProtected unless both --yes and --allow-protected are given.
+func ConfirmDestructive(label string) (bool, error) {
+	protected, err := IsProtected(CurrentContextName(), CurrentNamespace())
+	if err != nil {
+		return false, err
+	}
+	if !protected {
+		return utility.Confirm(label)
+	}
+
+	banner := fmt.Sprintf(" PROTECTED: context %s, namespace %s ", CurrentContextName(), CurrentNamespace())
+	fmt.Println(aurora.BgRed(aurora.White(strings.Repeat(" ", len(banner)))))
+	fmt.Println(aurora.BgRed(aurora.Bold(aurora.White(banner))))
+	fmt.Println(aurora.BgRed(aurora.White(strings.Repeat(" ", len(banner)))))
+
+	if utility.NonInteractive {
+		if AllowProtected && utility.AssumeYes {
+			fmt.Printf("%s %s\n", aurora.Bold(label), aurora.Red("yes (--yes and --allow-protected given)"))
+			return true, nil
+		}
+		return false, fmt.Errorf("%w: %s (pass --yes and --allow-protected to confirm)", ErrProtected, label)
+	}
+	// interactively, the namespace name must always be typed
+	if AllowProtected {
+		fmt.Println(aurora.Yellow("--allow-protected is only respected together with --non-interactive and --yes."))
+	}
+
+	prompt := promptui.Prompt{
+		Label: aurora.Bold(fmt.Sprintf("%s Type the namespace name %s to confirm", label, CurrentNamespace())),
+	}
+	answer, err := prompt.Run()
+	if err != nil {
+		return false, nil
+	}
+	return strings.TrimSpace(answer) == CurrentNamespace(), nil
+}
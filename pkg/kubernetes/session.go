@@ -0,0 +1,49 @@
+package kubernetes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// SessionKubeconfigEnvVar is exported by the shell hook of "sku shell-session"; it points to the kubeconfig of the session.
+const SessionKubeconfigEnvVar = "SKU_SESSION_KUBECONFIG"
+
+// CreateSessionKubeconfig writes a self-contained copy of the kubeconfig to a new temporary file (only readable by
+// the current user) and returns its path. The current context and namespace (including --context and --namespace)
+// are the ones of the session.
+func CreateSessionKubeconfig() (string, error) {
+	sessionConfig := KubernetesApiConfig().DeepCopy()
+	sessionConfig.CurrentContext = CurrentContextName()
+	if context, exists := sessionConfig.Contexts[CurrentContextName()]; exists {
+		context.Namespace = CurrentNamespace()
+	}
+
+	// certificates etc. are embedded, so that the session does not depend on other files.
+	if err := clientcmdapi.FlattenConfig(sessionConfig); err != nil {
+		return "", fmt.Errorf("could not flatten the kubeconfig: %w", err)
+	}
+
+	// TempFile creates the file with mode 0600.
+	file, err := ioutil.TempFile("", "sku-session-*.kubeconfig")
+	if err != nil {
+		return "", fmt.Errorf("could not create the session kubeconfig: %w", err)
+	}
+	file.Close()
+
+	if err = clientcmd.WriteToFile(*sessionConfig, file.Name()); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("could not write the session kubeconfig: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// InSession returns true if sku runs inside a "sku shell-session", i.e. context and namespace switches only
+// affect the current shell.
+func InSession() bool {
+	sessionKubeconfig := os.Getenv(SessionKubeconfigEnvVar)
+	return len(sessionKubeconfig) > 0 && os.Getenv(clientcmd.RecommendedConfigPathEnvVar) == sessionKubeconfig
+}
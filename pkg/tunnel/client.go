@@ -0,0 +1,164 @@
+package tunnel
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/sandstorm/sku/pkg/config"
+)
+
+const rpcServiceName = "TunnelDaemon"
+
+// how long to wait for a freshly started daemon to listen
+const daemonStartupTimeout = 10 * time.Second
+
+// DaemonCommand is the (hidden) sku command running the daemon.
+var DaemonCommand = []string{"tunnel", "daemon"}
+
+// Directory contains the sockets and logs of the daemons, next to the sku config.
+func Directory() string {
+	return filepath.Join(filepath.Dir(config.Current().Path()), "tunnels")
+}
+
+// SocketPath is the socket of the daemon of the context. Context names can be long (e.g. EKS ARNs), but socket
+// paths are limited to about 100 characters; so the name is hashed.
+func SocketPath(context string) (string, error) {
+	if err := os.MkdirAll(Directory(), 0700); err != nil {
+		return "", fmt.Errorf("could not create %s: %w", Directory(), err)
+	}
+	return filepath.Join(Directory(), contextHash(context)+".sock"), nil
+}
+
+// LogPath is the log file of the daemon of the context.
+func LogPath(context string) string {
+	return filepath.Join(Directory(), contextHash(context)+".log")
+}
+
+func contextHash(context string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(context))
+	return fmt.Sprintf("%08x", hash.Sum32())
+}
+
+// Client talks to the daemon of a context.
+type Client struct {
+	rpcClient *rpc.Client
+}
+
+// Connect connects to the daemon of the context; with start, the daemon is started if it is not running.
+func Connect(context string, start bool) (*Client, error) {
+	socketPath, err := SocketPath(context)
+	if err != nil {
+		return nil, err
+	}
+	rpcClient, err := rpc.Dial("unix", socketPath)
+	if err == nil {
+		return &Client{rpcClient}, nil
+	}
+	if !start {
+		return nil, fmt.Errorf("no tunnel daemon running for context %s: %w", context, err)
+	}
+
+	if err = startDaemon(context); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(daemonStartupTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(200 * time.Millisecond)
+		if rpcClient, err = rpc.Dial("unix", socketPath); err == nil {
+			return &Client{rpcClient}, nil
+		}
+	}
+	return nil, fmt.Errorf("the tunnel daemon did not start (see %s): %w", LogPath(context), err)
+}
+
+// startDaemon runs "sku --context <context> tunnel daemon" detached from the terminal, logging to LogPath.
+func startDaemon(context string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not find the sku executable: %w", err)
+	}
+	logFile, err := os.OpenFile(LogPath(context), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("could not open the log file of the tunnel daemon: %w", err)
+	}
+	defer logFile.Close()
+
+	command := exec.Command(executable, append([]string{"--context", context, "--non-interactive"}, DaemonCommand...)...)
+	command.Stdout = logFile
+	command.Stderr = logFile
+	// not below a project directory, whose .sku.yaml would pin the context
+	command.Dir = "/"
+	// a session of its own, so that it survives closing the terminal
+	command.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err = command.Start(); err != nil {
+		return fmt.Errorf("could not start the tunnel daemon: %w", err)
+	}
+	return command.Process.Release()
+}
+
+func (c *Client) Close() error {
+	return c.rpcClient.Close()
+}
+
+// Up opens the tunnel in the daemon.
+func (c *Client) Up(spec Spec) (Status, error) {
+	var status Status
+	err := c.rpcClient.Call(rpcServiceName+".Up", spec, &status)
+	return status, err
+}
+
+// Down closes the tunnel on the local port.
+func (c *Client) Down(localPort int) (Status, error) {
+	var status Status
+	err := c.rpcClient.Call(rpcServiceName+".Down", localPort, &status)
+	return status, err
+}
+
+// List returns the tunnels of the daemon.
+func (c *Client) List() ([]Status, error) {
+	var statuses []Status
+	err := c.rpcClient.Call(rpcServiceName+".List", struct{}{}, &statuses)
+	return statuses, err
+}
+
+// ListAll returns the tunnels of all running daemons, ordered by local port. The clients stay connected for
+// closing tunnels; close them when done.
+func ListAll() ([]Status, map[int]*Client, error) {
+	sockets, err := filepath.Glob(filepath.Join(Directory(), "*.sock"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	statuses := make([]Status, 0)
+	clients := make(map[int]*Client)
+	for _, socketPath := range sockets {
+		rpcClient, err := rpc.Dial("unix", socketPath)
+		if err != nil {
+			// a socket left over by a killed daemon
+			os.Remove(socketPath)
+			continue
+		}
+		client := &Client{rpcClient}
+		daemonStatuses, err := client.List()
+		if err != nil {
+			client.Close()
+			return nil, nil, err
+		}
+		for _, status := range daemonStatuses {
+			clients[status.LocalPort] = client
+		}
+		statuses = append(statuses, daemonStatuses...)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].LocalPort < statuses[j].LocalPort
+	})
+	return statuses, clients, nil
+}
@@ -0,0 +1,252 @@
+package tunnel
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sandstorm/sku/pkg/database"
+	"github.com/sandstorm/sku/pkg/kubernetes"
+)
+
+// a daemon without tunnels exits after this time
+const daemonIdleTimeout = 1 * time.Minute
+
+// Spec describes a tunnel to open in the daemon.
+type Spec struct {
+	// Name identifies the tunnel within its namespace, e.g. "mysql" or "elasticsearch:9200".
+	Name       string
+	Proxy      database.ProxySpec
+	TargetHost string
+	TargetPort int
+	// LocalPort is 0 to choose a free port.
+	LocalPort int
+}
+
+// Status is a tunnel running in a daemon.
+type Status struct {
+	Spec
+	Context string
+	// Pod is the pod the tunnel currently leads through; empty while reconnecting.
+	Pod     string
+	Started time.Time
+}
+
+type runningTunnel struct {
+	spec    Spec
+	forward *database.Forward
+	started time.Time
+}
+
+// Daemon keeps the tunnels of one kube context open; it is controlled via RPC over a Unix socket, and exits when
+// its last tunnel is closed.
+type Daemon struct {
+	context string
+	lock    sync.Mutex
+	tunnels map[int]*runningTunnel
+	// opening are the tunnels being opened by Up, as "<namespace>/<name>"; the daemon is not idle meanwhile.
+	opening  map[string]bool
+	listener net.Listener
+	stopping chan struct{}
+	stopOnce sync.Once
+}
+
+// RunDaemon serves the tunnels of the current context until the last tunnel is closed, or the daemon is terminated.
+func RunDaemon() error {
+	socketPath, err := SocketPath(kubernetes.CurrentContextName())
+	if err != nil {
+		return err
+	}
+	// a socket left over by a killed daemon
+	os.Remove(socketPath)
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("could not listen on %s: %w", socketPath, err)
+	}
+
+	daemon := &Daemon{
+		context:  kubernetes.CurrentContextName(),
+		tunnels:  make(map[int]*runningTunnel),
+		opening:  make(map[string]bool),
+		listener: listener,
+		stopping: make(chan struct{}),
+	}
+	server := rpc.NewServer()
+	if err = server.RegisterName(rpcServiceName, daemon); err != nil {
+		return err
+	}
+
+	terminated := make(chan os.Signal, 1)
+	signal.Notify(terminated, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-terminated
+		daemon.closeAll()
+	}()
+
+	// in case the sku which started the daemon does not open a tunnel
+	time.AfterFunc(daemonIdleTimeout, func() {
+		daemon.lock.Lock()
+		defer daemon.lock.Unlock()
+		daemon.stopIfIdle()
+	})
+
+	logf("tunnel daemon for context %s listening on %s", daemon.context, socketPath)
+	for {
+		connection, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-daemon.stopping:
+				logf("tunnel daemon stopped")
+				return nil
+			default:
+				return err
+			}
+		}
+		go server.ServeConn(connection)
+	}
+}
+
+// Up opens the tunnel; if a tunnel with the same name is already up in the namespace, its status is returned.
+func (d *Daemon) Up(spec Spec, reply *Status) error {
+	key := spec.Proxy.Namespace + "/" + spec.Name
+	d.lock.Lock()
+	for _, tunnel := range d.tunnels {
+		if tunnel.spec.Name == spec.Name && tunnel.spec.Proxy.Namespace == spec.Proxy.Namespace {
+			*reply = d.status(tunnel)
+			d.lock.Unlock()
+			return nil
+		}
+	}
+	if d.opening[key] {
+		d.lock.Unlock()
+		return fmt.Errorf("tunnel %s in namespace %s is being opened already", spec.Name, spec.Proxy.Namespace)
+	}
+	// opening the tunnel can take minutes (e.g. pulling the proxy image); it is reserved meanwhile
+	d.opening[key] = true
+	d.lock.Unlock()
+
+	forward, err := d.openForward(spec)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.opening, key)
+	if err != nil {
+		d.stopIfIdle()
+		return err
+	}
+	select {
+	case <-d.stopping:
+		// terminated while opening
+		forward.Close()
+		return fmt.Errorf("the tunnel daemon is stopping")
+	default:
+	}
+	spec.LocalPort = forward.LocalPort
+	tunnel := &runningTunnel{spec: spec, forward: forward, started: time.Now()}
+	d.tunnels[spec.LocalPort] = tunnel
+	*reply = d.status(tunnel)
+	return nil
+}
+
+func (d *Daemon) openForward(spec Spec) (*database.Forward, error) {
+	proxy, err := spec.Proxy.Proxy()
+	if err != nil {
+		logf("%v", err)
+		return nil, err
+	}
+	logf("opening tunnel %s in namespace %s", spec.Name, spec.Proxy.Namespace)
+	forward, err := database.StartForward(proxy, spec.LocalPort, spec.TargetHost, spec.TargetPort)
+	if err != nil {
+		logf("%v", err)
+		return nil, err
+	}
+	return forward, nil
+}
+
+// Down closes the tunnel on the local port; the daemon exits after the last one.
+func (d *Daemon) Down(localPort int, reply *Status) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	tunnel, found := d.tunnels[localPort]
+	if !found {
+		return fmt.Errorf("no tunnel on local port %d", localPort)
+	}
+	*reply = d.status(tunnel)
+	logf("closing tunnel %s in namespace %s", tunnel.spec.Name, tunnel.spec.Proxy.Namespace)
+	tunnel.forward.Close()
+	delete(d.tunnels, localPort)
+
+	d.stopIfIdle()
+	return nil
+}
+
+// stopIfIdle stops the daemon if there are no tunnels (anymore), and none are being opened; the lock must be held.
+func (d *Daemon) stopIfIdle() {
+	if !d.isIdle() {
+		return
+	}
+	// after the reply has been sent; unless a tunnel is opened meanwhile
+	time.AfterFunc(100*time.Millisecond, func() {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		if d.isIdle() {
+			d.stop()
+		}
+	})
+}
+
+func (d *Daemon) isIdle() bool {
+	return len(d.tunnels) == 0 && len(d.opening) == 0
+}
+
+func (d *Daemon) stop() {
+	d.stopOnce.Do(func() {
+		close(d.stopping)
+		d.listener.Close()
+	})
+}
+
+// List returns all tunnels of the daemon, ordered by local port.
+func (d *Daemon) List(_ struct{}, reply *[]Status) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	statuses := make([]Status, 0, len(d.tunnels))
+	for _, tunnel := range d.tunnels {
+		statuses = append(statuses, d.status(tunnel))
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].LocalPort < statuses[j].LocalPort
+	})
+	*reply = statuses
+	return nil
+}
+
+func (d *Daemon) status(tunnel *runningTunnel) Status {
+	return Status{
+		Spec:    tunnel.spec,
+		Context: d.context,
+		Pod:     tunnel.forward.PodName(),
+		Started: tunnel.started,
+	}
+}
+
+func (d *Daemon) closeAll() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for localPort, tunnel := range d.tunnels {
+		tunnel.forward.Close()
+		delete(d.tunnels, localPort)
+	}
+	d.stop()
+}
+
+// logf writes to the log file of the daemon (its stdout).
+func logf(format string, args ...interface{}) {
+	fmt.Printf("%s %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,95 @@
+package utility
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/manifoldco/promptui"
+)
+
+// ErrInteractionRequired is returned (wrapped) instead of showing a prompt in non-interactive mode.
+var ErrInteractionRequired = errors.New("user input required, but running non-interactively")
+
+// NonInteractive is set by --non-interactive: every prompt fails with ErrInteractionRequired.
+var NonInteractive = false
+
+// AssumeYes is set by --yes: all confirmations are answered with yes.
+var AssumeYes = false
+
+// InteractionRequired builds the error for a prompt which cannot be shown; hint tells how to avoid the prompt.
+func InteractionRequired(prompt string, hint string) error {
+	return fmt.Errorf("%w: %s (%s)", ErrInteractionRequired, prompt, hint)
+}
+
+// Confirm asks a yes/no question. It returns false if the user declined (or pressed Ctrl-C), and an error
+// if the question would need to be asked in non-interactive mode without --yes.
+func Confirm(label string) (bool, error) {
+	if AssumeYes {
+		fmt.Printf("%s %s\n", aurora.Bold(label), aurora.Green("yes (--yes given)"))
+		return true, nil
+	}
+	if NonInteractive {
+		return false, InteractionRequired(label, "pass --yes to confirm")
+	}
+
+	prompt := promptui.Prompt{
+		Label:     aurora.Bold(label),
+		IsConfirm: true,
+	}
+	_, err := prompt.Run()
+	if err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+// Select lets the user choose one of the items and returns its index. hint tells how to avoid the prompt
+// in non-interactive mode.
+func Select(label string, items interface{}, hint string) (int, error) {
+	if NonInteractive {
+		return 0, InteractionRequired(label, hint)
+	}
+
+	prompt := promptui.Select{
+		Label: aurora.Bold(label),
+		Items: items,
+	}
+	i, _, err := prompt.Run()
+	return i, err
+}
+
+// SelectFuzzy is like Select for a list of strings; typing filters the list by FuzzyMatch.
+func SelectFuzzy(label string, items []string, hint string) (int, error) {
+	if NonInteractive {
+		return 0, InteractionRequired(label, hint)
+	}
+
+	prompt := promptui.Select{
+		Label: aurora.Bold(label),
+		Items: items,
+		Size:  15,
+		Searcher: func(input string, index int) bool {
+			return FuzzyMatch(input, items[index])
+		},
+		StartInSearchMode: true,
+	}
+	i, _, err := prompt.Run()
+	return i, err
+}
+
+// FuzzyMatch returns true if all characters of pattern appear in text in the same order (ignoring case and spaces);
+// e.g. "webabc" matches "Deployment/web  web-7d9f-abc12".
+func FuzzyMatch(pattern, text string) bool {
+	text = strings.ToLower(text)
+	for _, r := range strings.ToLower(strings.ReplaceAll(pattern, " ", "")) {
+		i := strings.IndexRune(text, r)
+		if i < 0 {
+			return false
+		}
+		text = text[i+utf8.RuneLen(r):]
+	}
+	return true
+}
@@ -4,29 +4,36 @@ import (
 	"bufio"
 	"fmt"
 	"github.com/kardianos/osext"
-	"log"
 	"os"
 	"strconv"
 	"strings"
 )
 
-func GetSkuExecutableFileName() string {
+func GetSkuExecutableFileName() (string, error) {
 	skuExecutablePathAndFilename, err := osext.Executable()
 	if err != nil {
-		log.Fatalf("FATAL: could not find the executable path of the sku binary, error was: %v\n", err)
+		return "", fmt.Errorf("could not find the executable path of the sku binary: %w", err)
 	}
-	return skuExecutablePathAndFilename
-
+	return skuExecutablePathAndFilename, nil
 }
 
-func GetNumberChoice() int {
+// GetNumberChoice reads a number from stdin; hint tells how to avoid the prompt in non-interactive mode.
+func GetNumberChoice(hint string) (int, error) {
+	if NonInteractive {
+		return 0, InteractionRequired("a choice is needed", hint)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
 	for {
-		reader := bufio.NewReader(os.Stdin)
 		fmt.Print("Your Choice: ")
-		userInput, _ := reader.ReadString('\n')
+		userInput, readErr := reader.ReadString('\n')
 		i, err := strconv.Atoi(strings.TrimSpace(userInput))
 		if err == nil {
-			return i
+			return i, nil
+		}
+		if readErr != nil {
+			// stdin is closed, so we would loop forever.
+			return 0, fmt.Errorf("could not read choice from stdin: %w", readErr)
 		}
 	}
 }
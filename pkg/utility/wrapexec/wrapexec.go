@@ -30,7 +30,7 @@ func StartWrappedCommand(prefix string, name string, arg ...string) (*exec.Cmd,
 		// Read line by line and process it
 		for scanner.Scan() {
 			line := scanner.Text()
-			fmt.Sprintf("%s%s\n", prefix, line)
+			fmt.Printf("%s%s\n", prefix, line)
 		}
 
 		// We're all done, unblock the channel